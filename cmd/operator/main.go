@@ -146,6 +146,10 @@ func run(stop <-chan struct{}) {
 
 func newControllerConfig() controller.Config {
 	kubecli := k8sutil.MustNewKubeClient()
+	restcfg, err := k8sutil.InClusterConfig()
+	if err != nil {
+		logrus.Fatalf("fail to get in-cluster config: %v", err)
+	}
 
 	serviceAccount, err := getMyPodServiceAccount(kubecli)
 	if err != nil {
@@ -157,6 +161,7 @@ func newControllerConfig() controller.Config {
 		ClusterWide:    clusterWide,
 		ServiceAccount: serviceAccount,
 		KubeCli:        kubecli,
+		RestConfig:     restcfg,
 		KubeExtCli:     k8sutil.MustNewKubeExtClient(),
 		ZookeeperCRCli:      client.MustNewInCluster(),
 		CreateCRD:      createCRD,
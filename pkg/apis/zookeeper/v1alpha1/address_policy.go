@@ -0,0 +1,77 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type MemberAddressType string
+
+const (
+	// MemberAddressFQDN addresses the members by <member>.<cluster>.<namespace>.svc,
+	// followed by the cluster domain if it is set.
+	MemberAddressFQDN MemberAddressType = "FQDN"
+	// MemberAddressShortName addresses the members by <member>.<cluster>, which resolves
+	// through the DNS search path of the namespace.
+	MemberAddressShortName MemberAddressType = "ShortName"
+	// MemberAddressPodIP addresses the members by the IP of their pod.
+	MemberAddressPodIP MemberAddressType = "PodIP"
+)
+
+// MemberAddressPolicy defines how the members address each other and how the operator
+// reaches them.
+type MemberAddressPolicy struct {
+	// Type is FQDN, ShortName or PodIP. Default: FQDN.
+	Type MemberAddressType `json:"type,omitempty"`
+
+	// ClusterDomain is the DNS domain of the Kubernetes cluster, e.g. cluster.local,
+	// appended to the FQDN of the members.
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+}
+
+func (ap *MemberAddressPolicy) Validate() error {
+	switch ap.Type {
+	case "", MemberAddressFQDN:
+	case MemberAddressShortName, MemberAddressPodIP:
+		if len(ap.ClusterDomain) != 0 {
+			return fmt.Errorf("spec: memberAddress clusterDomain cannot be set with the %s type", ap.Type)
+		}
+	default:
+		return fmt.Errorf("spec: unknown memberAddress type (%s)", ap.Type)
+	}
+	if strings.HasPrefix(ap.ClusterDomain, ".") || strings.HasSuffix(ap.ClusterDomain, ".") {
+		return errors.New("spec: memberAddress clusterDomain must not start or end with a dot")
+	}
+	return nil
+}
+
+// AddressType returns how the members are addressed, FQDN if the policy is not set.
+func (ap *MemberAddressPolicy) AddressType() MemberAddressType {
+	if ap == nil || len(ap.Type) == 0 {
+		return MemberAddressFQDN
+	}
+	return ap.Type
+}
+
+// Domain returns the cluster domain appended to the FQDN of the members, if any.
+func (ap *MemberAddressPolicy) Domain() string {
+	if ap == nil {
+		return ""
+	}
+	return ap.ClusterDomain
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+)
+
+// AuthPolicy defines the authentication of the zookeeper cluster.
+type AuthPolicy struct {
+	// SASL enables SASL DIGEST-MD5 authentication of the clients and of the members.
+	SASL *SASLPolicy `json:"sasl,omitempty"`
+}
+
+type SASLPolicy struct {
+	// SuperUserSecret is the secret holding the password of the "super" user under the
+	// key "password". The operator authenticates as the super user to reconfigure the
+	// ensemble, since ACLs are enforced once SASL is enabled.
+	SuperUserSecret string `json:"superUserSecret"`
+
+	// ClientUsersSecret is the secret holding the users the clients authenticate as,
+	// with the user names as keys and their passwords as values.
+	ClientUsersSecret string `json:"clientUsersSecret,omitempty"`
+
+	// QuorumSecret is the secret holding the user the members authenticate to each other
+	// as, under the keys "username" and "password". Setting it enables quorum authentication.
+	QuorumSecret string `json:"quorumSecret,omitempty"`
+
+	// RequireQuorumSASL makes the members reject the quorum connections of members that
+	// do not authenticate. On a running cluster, the members are restarted with the quorum
+	// secret first, then required to authenticate as learners, then as servers.
+	RequireQuorumSASL bool `json:"requireQuorumSasl,omitempty"`
+}
+
+func (ap *AuthPolicy) Validate() error {
+	if ap.SASL == nil {
+		return nil
+	}
+	if len(ap.SASL.SuperUserSecret) == 0 {
+		return errors.New("spec: auth sasl superUserSecret must be set")
+	}
+	if ap.SASL.RequireQuorumSASL && len(ap.SASL.QuorumSecret) == 0 {
+		return errors.New("spec: auth sasl quorumSecret must be set if quorum SASL is required")
+	}
+	return nil
+}
+
+// IsSASLEnabled tells whether the members authenticate their clients with SASL.
+func (ap *AuthPolicy) IsSASLEnabled() bool {
+	return ap != nil && ap.SASL != nil
+}
+
+// IsQuorumSASLEnabled tells whether the members authenticate to each other with SASL.
+func (ap *AuthPolicy) IsQuorumSASLEnabled() bool {
+	return ap.IsSASLEnabled() && len(ap.SASL.QuorumSecret) != 0
+}
+
+// QuorumSASLTarget returns the quorum SASL phase the members are moved to.
+func (ap *AuthPolicy) QuorumSASLTarget() QuorumSASLPhase {
+	if ap.IsQuorumSASLEnabled() && ap.SASL.RequireQuorumSASL {
+		return QuorumSASLPhaseRequired
+	}
+	return QuorumSASLPhaseNone
+}
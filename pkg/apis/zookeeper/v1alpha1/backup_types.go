@@ -0,0 +1,290 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupStorageTypeS3 stores the backups in an S3 compatible object store.
+	BackupStorageTypeS3 BackupStorageType = "S3"
+	// BackupStorageTypePersistentVolume stores the backups on a persistent volume claim.
+	BackupStorageTypePersistentVolume BackupStorageType = "PersistentVolume"
+
+	// Keys of the secret holding the S3 credentials.
+	AWSAccessKeyID     = "accessKeyID"
+	AWSSecretAccessKey = "secretAccessKey"
+)
+
+type BackupStorageType string
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ZookeeperBackupList is a list of zookeeper backups.
+type ZookeeperBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: http://releases.k8s.io/HEAD/docs/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ZookeeperBackup `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ZookeeperBackup backs up the data of a zookeeper cluster, once or periodically.
+type ZookeeperBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              BackupSpec   `json:"spec"`
+	Status            BackupStatus `json:"status,omitempty"`
+}
+
+func (b *ZookeeperBackup) AsOwner() metav1.OwnerReference {
+	trueVar := true
+	return metav1.OwnerReference{
+		APIVersion: SchemeGroupVersion.String(),
+		Kind:       ZookeeperBackupResourceKind,
+		Name:       b.Name,
+		UID:        b.UID,
+		Controller: &trueVar,
+	}
+}
+
+// BackupSpec contains a backup specification for a zookeeper cluster.
+type BackupSpec struct {
+	// ClusterName is the zookeeper cluster to back up. It must be in the namespace
+	// of the backup.
+	ClusterName string `json:"clusterName"`
+
+	// StorageType is the type of the backup storage, S3 or PersistentVolume.
+	StorageType BackupStorageType `json:"storageType"`
+
+	// BackupSource is the backup storage source.
+	BackupSource `json:",inline"`
+
+	// BackupPolicy makes the backup periodic. Without it the cluster is backed up once.
+	BackupPolicy *BackupPolicy `json:"backupPolicy,omitempty"`
+}
+
+// BackupSource contains the supported backup storages.
+type BackupSource struct {
+	// S3 defines the S3 compatible object store the backups are written to.
+	S3 *S3BackupSource `json:"s3,omitempty"`
+
+	// PV defines the persistent volume claim the backups are written to.
+	PV *PVBackupSource `json:"pv,omitempty"`
+}
+
+type S3BackupSource struct {
+	// Path is the bucket and the prefix the backups are written under,
+	// in the format "<bucket>/<prefix>".
+	Path string `json:"path"`
+
+	// AWSSecret is the secret holding the credentials of the object store, under
+	// the keys "accessKeyID" and "secretAccessKey".
+	AWSSecret string `json:"awsSecret"`
+
+	// Region is the region of the bucket. Defaults to us-east-1.
+	Region string `json:"region,omitempty"`
+
+	// Endpoint is the URL of an S3 compatible object store, e.g. a MinIO server.
+	// The AWS endpoint of the region is used if it is empty.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// ForcePathStyle addresses the bucket in the path of the URL instead of the host
+	// name, as most S3 compatible object stores require.
+	ForcePathStyle bool `json:"forcePathStyle,omitempty"`
+}
+
+type PVBackupSource struct {
+	// ClaimName is the existing persistent volume claim the backups are written to.
+	ClaimName string `json:"claimName"`
+
+	// Path is the directory of the volume the backups are written under.
+	Path string `json:"path,omitempty"`
+}
+
+// RestorePolicy defines the backup a new cluster is seeded with.
+type RestorePolicy struct {
+	// StorageType is the type of the storage the backup is read from, S3 or PersistentVolume.
+	StorageType BackupStorageType `json:"storageType"`
+
+	// BackupSource is the storage the backup is read from. The path of the source is the
+	// path of the backup archive, as reported in the status of the ZookeeperBackup.
+	BackupSource `json:",inline"`
+}
+
+const (
+	defaultRecoveryGracePeriodInSecond     = 60
+	defaultRecoveryRestartIntervalInSecond = 10
+)
+
+// RecoveryPolicy defines how a cluster that lost its majority is recovered.
+type RecoveryPolicy struct {
+	// GracePeriodInSecond is how long the majority has to be down before the cluster is
+	// recovered, so that a member restarting does not trigger a recovery. Default: 60.
+	GracePeriodInSecond int64 `json:"gracePeriodInSecond,omitempty"`
+
+	// RestartIntervalInSecond is the minimum time between the restarts of two lost
+	// members. Default: 10.
+	RestartIntervalInSecond int64 `json:"restartIntervalInSecond,omitempty"`
+
+	// RebuildFromBackup allows rebuilding the cluster from the backup of BackupName when
+	// no member survived and the members have no persistent volume to restart from.
+	// The transactions since the backup are lost.
+	RebuildFromBackup bool `json:"rebuildFromBackup,omitempty"`
+
+	// BackupName is the ZookeeperBackup of the cluster, in its namespace. The cluster is
+	// rebuilt from its latest successful backup.
+	BackupName string `json:"backupName,omitempty"`
+}
+
+func (r *RecoveryPolicy) Validate() error {
+	if r.GracePeriodInSecond < 0 || r.RestartIntervalInSecond < 0 {
+		return errors.New("spec: recovery gracePeriodInSecond and restartIntervalInSecond must not be negative")
+	}
+	if r.RebuildFromBackup && len(r.BackupName) == 0 {
+		return errors.New("spec: recovery backupName must be set to rebuild from backup")
+	}
+	return nil
+}
+
+// GracePeriod returns how long the majority has to be down before the cluster is recovered.
+func (r *RecoveryPolicy) GracePeriod() time.Duration {
+	if r == nil || r.GracePeriodInSecond == 0 {
+		return defaultRecoveryGracePeriodInSecond * time.Second
+	}
+	return time.Duration(r.GracePeriodInSecond) * time.Second
+}
+
+// RestartInterval returns the minimum time between the restarts of two lost members.
+func (r *RecoveryPolicy) RestartInterval() time.Duration {
+	if r == nil || r.RestartIntervalInSecond == 0 {
+		return defaultRecoveryRestartIntervalInSecond * time.Second
+	}
+	return time.Duration(r.RestartIntervalInSecond) * time.Second
+}
+
+// IsRebuildFromBackupEnabled tells whether the cluster may be rebuilt from a backup.
+func (r *RecoveryPolicy) IsRebuildFromBackupEnabled() bool {
+	return r != nil && r.RebuildFromBackup
+}
+
+// BackupPolicy defines the schedule and the retention of the backups.
+type BackupPolicy struct {
+	// BackupIntervalInSecond is the time between two backups.
+	BackupIntervalInSecond int64 `json:"backupIntervalInSecond"`
+
+	// MaxBackups is the number of backups kept. The oldest backups are deleted
+	// beyond it. Zero keeps all backups.
+	MaxBackups int `json:"maxBackups,omitempty"`
+}
+
+// BackupStatus represents the status of the latest backup.
+type BackupStatus struct {
+	// Succeeded tells whether the latest backup succeeded.
+	Succeeded bool `json:"succeeded"`
+	// Reason is why the latest backup failed.
+	Reason string `json:"reason,omitempty"`
+
+	// Path is where the latest successful backup is stored.
+	Path string `json:"path,omitempty"`
+	// Member is the member the latest successful backup was taken from.
+	Member string `json:"member,omitempty"`
+	// Size is the size in bytes of the latest successful backup.
+	Size int64 `json:"size,omitempty"`
+	// Zxid is the zxid of the snapshot of the latest successful backup, in hexadecimal.
+	Zxid string `json:"zxid,omitempty"`
+	// Time is when the latest successful backup was taken.
+	Time string `json:"time,omitempty"`
+}
+
+func (s *BackupSpec) Validate() error {
+	if len(s.ClusterName) == 0 {
+		return errors.New("spec: clusterName must be set")
+	}
+	if err := s.BackupSource.validate("spec", s.StorageType); err != nil {
+		return err
+	}
+	if p := s.BackupPolicy; p != nil {
+		if p.BackupIntervalInSecond <= 0 {
+			return errors.New("spec: backupPolicy backupIntervalInSecond must be positive")
+		}
+		if p.MaxBackups < 0 {
+			return errors.New("spec: backupPolicy maxBackups must not be negative")
+		}
+	}
+	return nil
+}
+
+func (r *RestorePolicy) Validate() error {
+	if err := r.BackupSource.validate("spec: restore", r.StorageType); err != nil {
+		return err
+	}
+	if r.StorageType == BackupStorageTypePersistentVolume && len(r.PV.Path) == 0 {
+		return errors.New("spec: restore pv path must be set")
+	}
+	return nil
+}
+
+// Path returns the path of the backup archive.
+func (r *RestorePolicy) Path() string {
+	switch {
+	case r.S3 != nil:
+		return r.S3.Path
+	case r.PV != nil:
+		return r.PV.ClaimName + ":" + r.PV.Path
+	}
+	return ""
+}
+
+func (s *BackupSource) validate(field string, storageType BackupStorageType) error {
+	switch storageType {
+	case BackupStorageTypeS3:
+		if s.S3 == nil || len(s.S3.AWSSecret) == 0 {
+			return fmt.Errorf("%s: s3 awsSecret must be set", field)
+		}
+		if i := strings.Index(s.S3.Path, "/"); i <= 0 || i == len(s.S3.Path)-1 {
+			return fmt.Errorf(`%s: s3 path must be in the format "<bucket>/<prefix>"`, field)
+		}
+	case BackupStorageTypePersistentVolume:
+		if s.PV == nil || len(s.PV.ClaimName) == 0 {
+			return fmt.Errorf("%s: pv claimName must be set", field)
+		}
+		if strings.HasPrefix(s.PV.Path, "/") || containsDotDot(s.PV.Path) {
+			return fmt.Errorf(`%s: pv path must be relative to the volume and not contain ".."`, field)
+		}
+	default:
+		return fmt.Errorf("%s: storageType must be S3 or PersistentVolume", field)
+	}
+	return nil
+}
+
+// containsDotDot tells whether the path has a ".." element.
+func containsDotDot(p string) bool {
+	for _, e := range strings.Split(p, "/") {
+		if e == ".." {
+			return true
+		}
+	}
+	return false
+}
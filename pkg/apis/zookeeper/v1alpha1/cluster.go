@@ -16,8 +16,10 @@ package v1alpha1
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
+	"github.com/coreos/go-semver/semver"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -25,6 +27,9 @@ import (
 const (
 	defaultRepository  = "blafrisch/zookeeper"
 	DefaultZookeeperVersion = "3.5.3-beta"
+
+	// ImageTagFormatVersion is the placeholder of the version in ImageTagFormat.
+	ImageTagFormatVersion = "{version}"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -59,12 +64,92 @@ func (c *ZookeeperCluster) AsOwner() metav1.OwnerReference {
 	}
 }
 
+// JVMPolicy defines the JVM settings of the zookeeper process.
+// Fields left unset or at zero keep the JVM defaults.
 type JVMPolicy struct {
+	// HeapSizeInMB sets both the initial and the maximum heap size (-Xms, -Xmx).
+	// It must be lower than the memory limit of the zookeeper container, if any.
 	HeapSizeInMB int `json:"heapSizeInMB"`
 
+	// NewGenSizeInMB sets the size of the young generation (-Xmn).
+	// It must be lower than HeapSizeInMB.
 	NewGenSizeInMB int `json:"newGenSizeInMB"`
 
-	TunuringThreshold int `json:"tenuringThreshold"`
+	// TenuringThreshold sets the maximum tenuring threshold (-XX:MaxTenuringThreshold).
+	// The valid range is from 0 to 15. If not set, the JVM default is kept.
+	TenuringThreshold *int `json:"tenuringThreshold,omitempty"`
+}
+
+const maxTenuringThreshold = 15
+
+// DefaultMemberUpgradeTimeoutInSecond is the default time an upgraded member has to rejoin the cluster.
+const DefaultMemberUpgradeTimeoutInSecond = 300
+
+// DefaultMaxMinorVersionSkew is the default number of minor versions a version change may move by.
+const DefaultMaxMinorVersionSkew = 1
+
+// UpgradePolicy defines how the zookeeper members are upgraded.
+type UpgradePolicy struct {
+	// MemberTimeoutInSecond is the time an upgraded or restarted member has to become
+	// ready and synced with the leader. If it is exceeded, the upgrade stops and the
+	// Upgrading condition is set to False until the version is changed again. A rolling
+	// restart stops the same way, with the Restarting condition, until the pod spec, the
+	// image or the member secrets change.
+	//
+	// If not set, the default is 300 seconds.
+	MemberTimeoutInSecond int `json:"memberTimeoutInSecond,omitempty"`
+
+	// AllowMinorDowngrade allows moving to a version with a lower minor version,
+	// e.g. from 3.5 to 3.4. Zookeeper does not guarantee that an older minor version
+	// reads the data written by a newer one, so such downgrades are rejected by default.
+	AllowMinorDowngrade bool `json:"allowMinorDowngrade,omitempty"`
+
+	// MaxMinorVersionSkew is how many minor versions a single version change may move
+	// by, in either direction. With the default of 1, an upgrade from 3.4 to 3.6 must
+	// go through 3.5.
+	//
+	// If not set, the default is 1.
+	MaxMinorVersionSkew int `json:"maxMinorVersionSkew,omitempty"`
+
+	// AllowMajorVersionChange allows moving to a version with another major version.
+	// The minor version is not checked then. Such changes are rejected by default.
+	AllowMajorVersionChange bool `json:"allowMajorVersionChange,omitempty"`
+}
+
+func (u *UpgradePolicy) Validate() error {
+	if u.MemberTimeoutInSecond < 0 {
+		return errors.New("spec: upgrade memberTimeoutInSecond must not be negative")
+	}
+	if u.MaxMinorVersionSkew < 0 {
+		return errors.New("spec: upgrade maxMinorVersionSkew must not be negative")
+	}
+	return nil
+}
+
+func (j *JVMPolicy) Validate(pod *PodPolicy) error {
+	if j.HeapSizeInMB < 0 || j.NewGenSizeInMB < 0 {
+		return errors.New("spec: jvm heap sizes must not be negative")
+	}
+	if t := j.TenuringThreshold; t != nil && (*t < 0 || *t > maxTenuringThreshold) {
+		return fmt.Errorf("spec: jvm tenuringThreshold must be between 0 and %d", maxTenuringThreshold)
+	}
+	if j.HeapSizeInMB > 0 && j.NewGenSizeInMB >= j.HeapSizeInMB {
+		return errors.New("spec: jvm newGenSizeInMB must be lower than heapSizeInMB")
+	}
+	if pod == nil {
+		return nil
+	}
+	if limit, ok := pod.Resources.Limits[v1.ResourceMemory]; ok && j.HeapSizeInMB > 0 {
+		if int64(j.HeapSizeInMB)*1024*1024 >= limit.Value() {
+			return fmt.Errorf("spec: jvm heapSizeInMB (%d) must be lower than the pod memory limit (%s)", j.HeapSizeInMB, limit.String())
+		}
+	}
+	for _, env := range pod.ZookeeperEnv {
+		if env.Name == "JVMFLAGS" {
+			return errors.New("spec: pod zookeeperEnv must not set JVMFLAGS when the jvm policy is used")
+		}
+	}
+	return nil
 }
 
 type ClusterSpec struct {
@@ -82,6 +167,20 @@ type ClusterSpec struct {
 	// By default, it is `zookeeper`.
 	Repository string `json:"repository,omitempty"`
 
+	// ImageTagFormat is the format of the image tags in Repository. "{version}" is
+	// replaced by the version, e.g. "{version}" for a mirror without the "v" prefix.
+	//
+	// By default, it is "v{version}".
+	ImageTagFormat string `json:"imageTagFormat,omitempty"`
+
+	// Image overrides the zookeeper image built from Repository, ImageTagFormat and Version,
+	// e.g. to pin the image by digest: "registry.example.com/zookeeper@sha256:<digest>".
+	// The image must run the zookeeper version given by Version, which is still used to
+	// order and check upgrades.
+	//
+	// Changing Image upgrades the zookeeper members one by one.
+	Image string `json:"image,omitempty"`
+
 	// Version is the expected version of the zookeeper cluster.
 	// The zookeeper-operator will eventually make the zookeeper cluster version
 	// equal to the expected version.
@@ -97,11 +196,197 @@ type ClusterSpec struct {
 
 	// Pod defines the policy to create pod for the zookeeper pod.
 	//
-	// Updating Pod does not take effect on any existing zookeeper pods.
+	// Updating Pod restarts the zookeeper members one by one.
 	Pod *PodPolicy `json:"pod,omitempty"`
 
-	// zookeeper JVM policy
+	// Config defines the zoo.cfg settings of the zookeeper members.
+	//
+	// Updating Config restarts the zookeeper members one by one.
+	Config *ZookeeperConfig `json:"config,omitempty"`
+
+	// JVM defines the JVM settings of the zookeeper process.
+	//
+	// Updating JVM restarts the zookeeper members one by one.
 	JVM *JVMPolicy `json:"jvm,omitempty"`
+
+	// Upgrade defines how the zookeeper members are upgraded.
+	Upgrade *UpgradePolicy `json:"upgrade,omitempty"`
+
+	// TLS defines the TLS settings of the zookeeper cluster.
+	//
+	// Updating TLS restarts the zookeeper members one by one.
+	TLS *TLSPolicy `json:"tls,omitempty"`
+
+	// Auth defines the authentication of the clients and of the zookeeper members.
+	//
+	// Updating Auth restarts the zookeeper members one by one.
+	Auth *AuthPolicy `json:"auth,omitempty"`
+
+	// Restore seeds a new cluster with the data of a backup. The seed member starts from
+	// the backup and the other members join it as usual.
+	//
+	// Restore is only read when the cluster is created.
+	Restore *RestorePolicy `json:"restore,omitempty"`
+
+	// Recovery defines how the cluster is recovered when its majority is lost.
+	Recovery *RecoveryPolicy `json:"recovery,omitempty"`
+
+	// ExternalAccess exposes every member outside of the Kubernetes cluster with a
+	// NodePort or LoadBalancer service of its own. The addresses of the members are
+	// published in the status.
+	ExternalAccess *ExternalAccessPolicy `json:"externalAccess,omitempty"`
+
+	// Service customizes the client service, e.g. to expose it through a load balancer
+	// or on another port.
+	Service *ServicePolicy `json:"service,omitempty"`
+
+	// MemberAddress defines how the members address each other and how the operator
+	// reaches them, by FQDN, by short name or by pod IP. It cannot be changed once
+	// the cluster is created.
+	MemberAddress *MemberAddressPolicy `json:"memberAddress,omitempty"`
+
+	// Topology spreads the members over the domains of a topology key. New members are
+	// placed in the domain with the fewest members.
+	Topology *TopologyPolicy `json:"topology,omitempty"`
+
+	// Migration moves the members off nodes that are not ready or cordoned, one at a
+	// time, through the replacement of dead members.
+	Migration *MigrationPolicy `json:"migration,omitempty"`
+
+	// Observers adds non-voting members to the cluster. They never count toward the
+	// quorum and are not part of Size.
+	Observers *ObserverPolicy `json:"observers,omitempty"`
+}
+
+// ZookeeperConfig defines the zoo.cfg settings of the zookeeper members.
+// Fields left at zero keep the zookeeper defaults.
+type ZookeeperConfig struct {
+	// TickTime is the length of a single tick in milliseconds.
+	TickTime int `json:"tickTime,omitempty"`
+
+	// InitLimit is the number of ticks followers may take to connect and sync to the leader.
+	InitLimit int `json:"initLimit,omitempty"`
+
+	// SyncLimit is the number of ticks a follower may lag behind the leader.
+	SyncLimit int `json:"syncLimit,omitempty"`
+
+	// MaxClientCnxns limits the number of concurrent connections of a single client IP.
+	// 0 means unlimited. If not set, connections are unlimited.
+	MaxClientCnxns *int `json:"maxClientCnxns,omitempty"`
+
+	// AutoPurgeSnapRetainCount is the number of snapshots and txn logs kept by the auto purge.
+	// The minimum is 3.
+	AutoPurgeSnapRetainCount int `json:"autoPurgeSnapRetainCount,omitempty"`
+
+	// AutoPurgePurgeInterval is the interval of the auto purge in hours. 0 disables it.
+	AutoPurgePurgeInterval int `json:"autoPurgePurgeInterval,omitempty"`
+
+	// GlobalOutstandingLimit is the maximum number of queued requests.
+	GlobalOutstandingLimit int `json:"globalOutstandingLimit,omitempty"`
+
+	// PreAllocSize is the size in KB the txn log files are preallocated with.
+	PreAllocSize int `json:"preAllocSize,omitempty"`
+
+	// SnapCount is the number of transactions logged before a snapshot is taken.
+	SnapCount int `json:"snapCount,omitempty"`
+
+	// FourLetterWordsWhitelist is the list of four letter words the members answer to.
+	// "*" enables all of them. The commands the operator depends on, e.g. ruok for
+	// the pod probes, are always enabled.
+	FourLetterWordsWhitelist []string `json:"fourLetterWordsWhitelist,omitempty"`
+}
+
+var fourLetterWords = map[string]bool{
+	"*": true, "conf": true, "cons": true, "crst": true, "dirs": true, "dump": true,
+	"envi": true, "gtmk": true, "isro": true, "mntr": true, "ruok": true, "srst": true,
+	"srvr": true, "stat": true, "stmk": true, "wchc": true, "wchp": true, "wchs": true,
+}
+
+// minSnapRetainCount is the lowest snapRetainCount zookeeper accepts.
+const minSnapRetainCount = 3
+
+func (zc *ZookeeperConfig) Validate() error {
+	if zc.TickTime < 0 || zc.InitLimit < 0 || zc.SyncLimit < 0 {
+		return errors.New("spec: config tickTime, initLimit and syncLimit must not be negative")
+	}
+	if zc.MaxClientCnxns != nil && *zc.MaxClientCnxns < 0 {
+		return errors.New("spec: config maxClientCnxns must not be negative")
+	}
+	if zc.AutoPurgeSnapRetainCount != 0 && zc.AutoPurgeSnapRetainCount < minSnapRetainCount {
+		return fmt.Errorf("spec: config autoPurgeSnapRetainCount must be at least %d", minSnapRetainCount)
+	}
+	if zc.AutoPurgePurgeInterval < 0 {
+		return errors.New("spec: config autoPurgePurgeInterval must not be negative")
+	}
+	if zc.GlobalOutstandingLimit < 0 || zc.PreAllocSize < 0 || zc.SnapCount < 0 {
+		return errors.New("spec: config globalOutstandingLimit, preAllocSize and snapCount must not be negative")
+	}
+	for _, w := range zc.FourLetterWordsWhitelist {
+		if !fourLetterWords[w] {
+			return fmt.Errorf("spec: config fourLetterWordsWhitelist contains unknown command %q", w)
+		}
+	}
+	return nil
+}
+
+// zookeeperEnvChecks rejects environment variables that would break the ensemble
+// management of the operator. An empty value forbids the variable entirely.
+var zookeeperEnvChecks = map[string]string{
+	"ZOO_MY_ID":              "",
+	"ZOO_SERVERS":            "",
+	"ZOO_STANDALONE_ENABLED": "false",
+	"ZOO_RECONFIG_ENABLED":   "true",
+}
+
+// configEnvNames maps the environment variables of the zookeeper image to the
+// ZookeeperConfig fields replacing them.
+var configEnvNames = map[string]func(*ZookeeperConfig) bool{
+	"ZOO_TICK_TIME":                 func(zc *ZookeeperConfig) bool { return zc.TickTime != 0 },
+	"ZOO_INIT_LIMIT":                func(zc *ZookeeperConfig) bool { return zc.InitLimit != 0 },
+	"ZOO_SYNC_LIMIT":                func(zc *ZookeeperConfig) bool { return zc.SyncLimit != 0 },
+	"ZOO_MAX_CLIENT_CNXNS":          func(zc *ZookeeperConfig) bool { return zc.MaxClientCnxns != nil },
+	"ZOO_AUTOPURGE_SNAPRETAINCOUNT": func(zc *ZookeeperConfig) bool { return zc.AutoPurgeSnapRetainCount != 0 },
+	"ZOO_AUTOPURGE_PURGEINTERVAL":   func(zc *ZookeeperConfig) bool { return zc.AutoPurgePurgeInterval != 0 },
+	"ZOO_4LW_WHITELIST":             func(zc *ZookeeperConfig) bool { return len(zc.FourLetterWordsWhitelist) != 0 },
+	"SERVER_JVMFLAGS": func(zc *ZookeeperConfig) bool {
+		return zc.GlobalOutstandingLimit != 0 || zc.PreAllocSize != 0 || zc.SnapCount != 0
+	},
+}
+
+// tlsEnvNames are the environment variables the operator sets up for TLS.
+var tlsEnvNames = map[string]bool{
+	"ZOO_CFG_EXTRA":   true,
+	"SERVER_JVMFLAGS": true,
+}
+
+// authEnvNames are the environment variables the operator sets up for authentication.
+var authEnvNames = map[string]bool{
+	"ZOO_CFG_EXTRA":   true,
+	"SERVER_JVMFLAGS": true,
+	"ZOO_SKIP_ACL":    true,
+}
+
+func validateZookeeperEnv(env []v1.EnvVar, zc *ZookeeperConfig, tp *TLSPolicy, ap *AuthPolicy) error {
+	for _, e := range env {
+		if tlsEnvNames[e.Name] && (tp.IsSecureClient() || tp.IsSecurePeer()) {
+			return fmt.Errorf("spec: pod zookeeperEnv sets %s which is already configured for TLS", e.Name)
+		}
+		if authEnvNames[e.Name] && ap.IsSASLEnabled() {
+			return fmt.Errorf("spec: pod zookeeperEnv sets %s which is already configured for SASL", e.Name)
+		}
+		if allowed, ok := zookeeperEnvChecks[e.Name]; ok {
+			if len(allowed) == 0 {
+				return fmt.Errorf("spec: pod zookeeperEnv must not set %s", e.Name)
+			}
+			if e.ValueFrom != nil || !strings.EqualFold(e.Value, allowed) {
+				return fmt.Errorf("spec: pod zookeeperEnv must not set %s to anything but %q", e.Name, allowed)
+			}
+		}
+		if isSet, ok := configEnvNames[e.Name]; ok && zc != nil && isSet(zc) {
+			return fmt.Errorf("spec: pod zookeeperEnv sets %s which is already configured by the config section", e.Name)
+		}
+	}
+	return nil
 }
 
 // PodPolicy defines the policy to create pod for the zookeeper container.
@@ -123,7 +408,6 @@ type PodPolicy struct {
 	AntiAffinity bool `json:"antiAffinity,omitempty"`
 
 	// Resources is the resource requirements for the zookeeper container.
-	// This field cannot be updated once the cluster is created.
 	Resources v1.ResourceRequirements `json:"resources,omitempty"`
 
 	// Tolerations specifies the pod's tolerations.
@@ -131,48 +415,183 @@ type PodPolicy struct {
 
 	// List of environment variables to set in the zookeeper container.
 	// This is used to configure zookeeper process. zookeeper cluster cannot be created, when
-	// bad environement variables are provided. Do not overwrite any flags used to
-	// bootstrap the cluster (for example `--initial-cluster` flag).
-	// This field cannot be updated.
+	// bad environement variables are provided. Do not overwrite any variables used to
+	// bootstrap the cluster (ZOO_MY_ID and ZOO_SERVERS), and prefer the config section
+	// over the ZOO_* variables it covers.
 	ZookeeperEnv []v1.EnvVar `json:"zookeeperEnv,omitempty"`
 
 	// PersistentVolumeClaimSpec is the spec to describe PVC for the zookeeper container
 	// This field is optional. If no PVC spec, zookeeper container will use emptyDir as volume
-	// Note. This feature is in alpha stage. It is currently only used as non-stable storage,
-	// not the stable storage. Future work need to make it used as stable storage.
+	// Each member gets its own PVC holding the data directory, and the transaction log unless
+	// TlogPersistentVolumeClaimSpec is set. The PVC is kept when a dead member is replaced and
+	// deleted when the member is removed from the cluster.
 	PersistentVolumeClaimSpec *v1.PersistentVolumeClaimSpec `json:"persistentVolumeClaimSpec,omitempty"`
 
+	// TlogPersistentVolumeClaimSpec is the spec to describe the PVC for the zookeeper transaction log.
+	// This field is optional. If set, each member gets a second PVC mounted at /datalog, which allows
+	// putting the transaction log on a dedicated disk, e.g. by using a faster storage class.
+	// If not set, the transaction log is kept on the data volume.
+	TlogPersistentVolumeClaimSpec *v1.PersistentVolumeClaimSpec `json:"tlogPersistentVolumeClaimSpec,omitempty"`
+
 	// Annotations specifies the annotations to attach to pods the operator creates for the
 	// zookeeper cluster.
-	// The "zookeeper.version" annotation is reserved for the internal use of the zookeeper operator.
+	// The "zookeeper.version" and "zookeeper.podtemplate.hash" annotations are reserved for the
+	// internal use of the zookeeper operator.
 	Annotations map[string]string `json:"annotations,omitempty"`
 
 	// busybox init container image. default is busybox:1.28.0-glibc
 	// busybox:latest uses uclibc which contains a bug that sometimes prevents name resolution
 	// More info: https://github.com/docker-library/busybox/issues/27
 	BusyboxImage string `json:"busyboxImage,omitempty"`
+
+	// ImagePullSecrets are the secrets used to pull the zookeeper and busybox images
+	// from a private registry.
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// ImagePullPolicy is the pull policy of the zookeeper and busybox images.
+	// If not set, it is defaulted by Kubernetes based on the image tag.
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
 }
 
 // TODO: move this to initializer
 func (c *ClusterSpec) Validate() error {
-	/*
+	version, err := semver.NewVersion(c.Version)
+	if err != nil {
+		return fmt.Errorf("spec: invalid version %q: %v", c.Version, err)
+	}
+	if len(c.ImageTagFormat) != 0 && !strings.Contains(c.ImageTagFormat, ImageTagFormatVersion) {
+		return fmt.Errorf("spec: imageTagFormat must contain %s", ImageTagFormatVersion)
+	}
+	if i := strings.Index(c.Image, "@"); i >= 0 && !strings.HasPrefix(c.Image[i+1:], "sha256:") {
+		return fmt.Errorf("spec: image %q must be pinned by a sha256 digest", c.Image)
+	}
+
 	if c.TLS != nil {
 		if err := c.TLS.Validate(); err != nil {
 			return err
 		}
+		if c.TLS.IsSecurePeer() && version.LessThan(*semver.New(QuorumTLSMinVersion)) {
+			return fmt.Errorf("spec: tls static member peerSecret requires zookeeper %s or later, got %s", QuorumTLSMinVersion, c.Version)
+		}
+	}
+
+	if c.Auth != nil {
+		if err := c.Auth.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Restore != nil {
+		if err := c.Restore.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Recovery != nil {
+		if err := c.Recovery.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.ExternalAccess != nil {
+		if err := c.ExternalAccess.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Migration != nil {
+		if err := c.Migration.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Topology != nil {
+		if err := c.Topology.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.MemberAddress != nil {
+		if err := c.MemberAddress.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Service != nil {
+		if err := c.Service.Validate(); err != nil {
+			return err
+		}
+		if c.TLS.IsSecureClient() && c.Service.ClientPort == SecureClientPort {
+			return fmt.Errorf("spec: service clientPort conflicts with the secure client port %d", SecureClientPort)
+		}
+	}
+
+	if c.Config != nil {
+		if err := c.Config.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.JVM != nil {
+		if err := c.JVM.Validate(c.Pod); err != nil {
+			return err
+		}
+	}
+
+	if c.Upgrade != nil {
+		if err := c.Upgrade.Validate(); err != nil {
+			return err
+		}
 	}
-	*/
 
 	if c.Pod != nil {
-		for k := range c.Pod.Labels {
-			if k == "app" || strings.HasPrefix(k, "zookeeper_") {
-				return errors.New("spec: pod labels contains reserved label")
+		if err := c.validatePodPolicy("pod", c.Pod); err != nil {
+			return err
+		}
+	}
+
+	if c.Observers != nil {
+		if err := c.Observers.Validate(); err != nil {
+			return err
+		}
+		if c.Observers.Pod != nil {
+			if err := c.validatePodPolicy("observers pod", c.Observers.Pod); err != nil {
+				return err
+			}
+			if c.JVM != nil {
+				if err := c.JVM.Validate(c.Observers.Pod); err != nil {
+					return err
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// validatePodPolicy validates a pod policy the zookeeper members are created from. The
+// name tells which one in the errors.
+func (c *ClusterSpec) validatePodPolicy(name string, p *PodPolicy) error {
+	for k := range p.Labels {
+		if k == "app" || strings.HasPrefix(k, "zookeeper_") {
+			return fmt.Errorf("spec: %s labels contains reserved label", name)
+		}
+	}
+	for k := range p.Annotations {
+		if k == "zookeeper.version" || k == "zookeeper.podtemplate.hash" || k == "zookeeper.member.secrets.hash" {
+			return fmt.Errorf("spec: %s annotations contains reserved annotation", name)
+		}
+	}
+	if err := validateZookeeperEnv(p.ZookeeperEnv, c.Config, c.TLS, c.Auth); err != nil {
+		return err
+	}
+	switch p.ImagePullPolicy {
+	case "", v1.PullAlways, v1.PullIfNotPresent, v1.PullNever:
+	default:
+		return fmt.Errorf("spec: %s imagePullPolicy %q is not supported", name, p.ImagePullPolicy)
+	}
+	return nil
+}
+
 // SetDefaults cleans up user passed spec, e.g. defaulting, transforming fields.
 // TODO: move this to initializer
 func (e *ZookeeperCluster) SetDefaults() {
@@ -189,14 +608,21 @@ func (e *ZookeeperCluster) SetDefaults() {
 
 	// convert PodPolicy.AntiAffinity to Pod.Affinity.PodAntiAffinity
 	// TODO: Remove this once PodPolicy.AntiAffinity is removed
-	if c.Pod != nil && c.Pod.AntiAffinity && c.Pod.Affinity == nil {
-		c.Pod.Affinity = &v1.Affinity{
+	setAntiAffinityDefault(c.Pod, e.Name)
+	if c.Observers != nil {
+		setAntiAffinityDefault(c.Observers.Pod, e.Name)
+	}
+}
+
+func setAntiAffinityDefault(p *PodPolicy, clusterName string) {
+	if p != nil && p.AntiAffinity && p.Affinity == nil {
+		p.Affinity = &v1.Affinity{
 			PodAntiAffinity: &v1.PodAntiAffinity{
 				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
 					{
 						// set anti-affinity to the zookeeper pods that belongs to the same cluster
 						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{
-							"zookeeper_cluster": e.Name,
+							"zookeeper_cluster": clusterName,
 						}},
 						TopologyKey: "kubernetes.io/hostname",
 					},
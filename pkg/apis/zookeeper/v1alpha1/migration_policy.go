@@ -0,0 +1,68 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultMigrationIntervalInSecond = 600
+	defaultNodeNotReadyInSecond      = 60
+)
+
+// MigrationPolicy moves the members off nodes that are not ready or cordoned, one at a
+// time, before the eviction of their pods takes several members down at once.
+type MigrationPolicy struct {
+	// Enabled turns the migration on.
+	Enabled bool `json:"enabled"`
+
+	// MinIntervalInSecond is the minimum time between two migrations. Default: 600.
+	MinIntervalInSecond int64 `json:"minIntervalInSecond,omitempty"`
+
+	// NodeNotReadyInSecond is how long a node must have been not ready before its
+	// members are moved. Cordoned nodes are left right away. Default: 60.
+	NodeNotReadyInSecond int64 `json:"nodeNotReadyInSecond,omitempty"`
+}
+
+func (mp *MigrationPolicy) Validate() error {
+	if mp.MinIntervalInSecond < 0 || mp.NodeNotReadyInSecond < 0 {
+		return errors.New("spec: migration intervals must not be negative")
+	}
+	return nil
+}
+
+// IsEnabled tells whether members are moved off unhealthy nodes.
+func (mp *MigrationPolicy) IsEnabled() bool {
+	return mp != nil && mp.Enabled
+}
+
+// MinInterval returns the minimum time between two migrations.
+func (mp *MigrationPolicy) MinInterval() time.Duration {
+	if mp.MinIntervalInSecond == 0 {
+		return defaultMigrationIntervalInSecond * time.Second
+	}
+	return time.Duration(mp.MinIntervalInSecond) * time.Second
+}
+
+// NodeNotReadyTimeout returns how long a node must have been not ready before its
+// members are moved.
+func (mp *MigrationPolicy) NodeNotReadyTimeout() time.Duration {
+	if mp.NodeNotReadyInSecond == 0 {
+		return defaultNodeNotReadyInSecond * time.Second
+	}
+	return time.Duration(mp.NodeNotReadyInSecond) * time.Second
+}
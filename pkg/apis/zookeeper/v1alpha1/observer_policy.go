@@ -0,0 +1,50 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+)
+
+// ObserverPolicy defines the observers of the zookeeper cluster. Observers are members
+// that serve reads and forward writes without voting, so they add read capacity, e.g.
+// close to clients at remote sites, without slowing down the writes or weakening the
+// quorum.
+type ObserverPolicy struct {
+	// Count is the number of observers. Observers are added and removed one at a time,
+	// independently of the size of the cluster.
+	Count int `json:"count"`
+
+	// Pod defines the policy to create the pods of the observers. If not set, the
+	// observers are created from the pod policy of the cluster.
+	//
+	// Updating Pod restarts the observers one by one.
+	Pod *PodPolicy `json:"pod,omitempty"`
+}
+
+func (op *ObserverPolicy) Validate() error {
+	if op.Count < 0 {
+		return errors.New("spec: observers count must not be negative")
+	}
+	return nil
+}
+
+// Size returns the number of observers, 0 if there is no observer policy.
+func (op *ObserverPolicy) Size() int {
+	if op == nil {
+		return 0
+	}
+	return op.Count
+}
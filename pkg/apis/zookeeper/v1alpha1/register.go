@@ -23,6 +23,8 @@ import (
 const (
 	ZookeeperClusterResourceKind   = "ZookeeperCluster"
 	ZookeeperClusterResourcePlural = "zookeeperclusters"
+	ZookeeperBackupResourceKind    = "ZookeeperBackup"
+	ZookeeperBackupResourcePlural  = "zookeeperbackups"
 	groupName                 = "zookeeper.database.apache.com"
 )
 
@@ -32,6 +34,7 @@ var (
 
 	SchemeGroupVersion = schema.GroupVersion{Group: groupName, Version: "v1alpha1"}
 	ZookeeperClusterCRDName = ZookeeperClusterResourcePlural + "." + groupName
+	ZookeeperBackupCRDName  = ZookeeperBackupResourcePlural + "." + groupName
 )
 
 // Resource gets an ZookeeperCluster GroupResource for a specified resource
@@ -44,6 +47,8 @@ func addKnownTypes(s *runtime.Scheme) error {
 	s.AddKnownTypes(SchemeGroupVersion,
 		&ZookeeperCluster{},
 		&ZookeeperClusterList{},
+		&ZookeeperBackup{},
+		&ZookeeperBackupList{},
 	)
 	metav1.AddToGroupVersion(s, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,117 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/api/core/v1"
+)
+
+// ExternalAccessPolicy exposes every member outside of the Kubernetes cluster with a
+// service of its own.
+type ExternalAccessPolicy struct {
+	// Type is the type of the member services, NodePort or LoadBalancer.
+	Type v1.ServiceType `json:"type"`
+
+	// Annotations are set on the member services, e.g. to configure the load balancers
+	// of the cloud provider.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (e *ExternalAccessPolicy) Validate() error {
+	if e.Type != v1.ServiceTypeNodePort && e.Type != v1.ServiceTypeLoadBalancer {
+		return fmt.Errorf("spec: externalAccess type must be %s or %s", v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer)
+	}
+	return nil
+}
+
+// Default quorum and leader election ports of the members.
+const (
+	DefaultPeerPort   = 2888
+	DefaultLeaderPort = 3888
+)
+
+// ServicePolicy defines the client service of the cluster and the quorum and leader
+// election ports of the members. The members keep listening on their default client
+// port, the client port of the service is mapped to theirs.
+type ServicePolicy struct {
+	// Type is the type of the client service, ClusterIP by default.
+	Type v1.ServiceType `json:"type,omitempty"`
+
+	// Annotations and Labels are set on the client service.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+
+	// LoadBalancerSourceRanges restricts the clients of a LoadBalancer client service.
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// ClientPort is the plaintext client port of the client service, 2181 by default.
+	ClientPort int32 `json:"clientPort,omitempty"`
+	// PeerPort is the port the members listen on for the followers to connect to the
+	// leader, 2888 by default. The peer service exposes it.
+	PeerPort int32 `json:"peerPort,omitempty"`
+	// LeaderPort is the port the members listen on for the leader election, 3888 by
+	// default. The peer service exposes it.
+	LeaderPort int32 `json:"leaderPort,omitempty"`
+}
+
+// MemberPeerPort returns the quorum port of the members.
+func (sp *ServicePolicy) MemberPeerPort() int {
+	if sp == nil || sp.PeerPort == 0 {
+		return DefaultPeerPort
+	}
+	return int(sp.PeerPort)
+}
+
+// MemberLeaderPort returns the leader election port of the members.
+func (sp *ServicePolicy) MemberLeaderPort() int {
+	if sp == nil || sp.LeaderPort == 0 {
+		return DefaultLeaderPort
+	}
+	return int(sp.LeaderPort)
+}
+
+func (sp *ServicePolicy) Validate() error {
+	switch sp.Type {
+	case "", v1.ServiceTypeClusterIP, v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("spec: service type must be %s, %s or %s", v1.ServiceTypeClusterIP, v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer)
+	}
+	if len(sp.LoadBalancerSourceRanges) != 0 && sp.Type != v1.ServiceTypeLoadBalancer {
+		return errors.New("spec: service loadBalancerSourceRanges requires the LoadBalancer type")
+	}
+	for _, k := range []string{"app", "zookeeper_cluster"} {
+		if _, ok := sp.Labels[k]; ok {
+			return fmt.Errorf("spec: service label (%s) is reserved for internal use", k)
+		}
+	}
+	for name, p := range map[string]int32{"clientPort": sp.ClientPort, "peerPort": sp.PeerPort, "leaderPort": sp.LeaderPort} {
+		if p < 0 || p > 65535 {
+			return fmt.Errorf("spec: service %s (%d) is out of range", name, p)
+		}
+	}
+	peer, leader := sp.MemberPeerPort(), sp.MemberLeaderPort()
+	if peer == leader {
+		return fmt.Errorf("spec: service peerPort and leaderPort must differ, both are %d", peer)
+	}
+	for _, p := range []int{MemberClientPort, SecureClientPort} {
+		if peer == p || leader == p {
+			return fmt.Errorf("spec: service peerPort and leaderPort must not be the client port %d of the members", p)
+		}
+	}
+	return nil
+}
@@ -31,10 +31,13 @@ const (
 	ClusterPhaseFailed                = "Failed"
 
 	// See ./doc/user/conditions_and_events.md
-	ClusterConditionAvailable  ClusterConditionType = "Available"
-	ClusterConditionRecovering                      = "Recovering"
-	ClusterConditionScaling                         = "Scaling"
-	ClusterConditionUpgrading                       = "Upgrading"
+	ClusterConditionAvailable      ClusterConditionType = "Available"
+	ClusterConditionRecovering                          = "Recovering"
+	ClusterConditionScaling                             = "Scaling"
+	ClusterConditionUpgrading                           = "Upgrading"
+	ClusterConditionSpecRejected                        = "SpecRejected"
+	ClusterConditionTopologySkewed                      = "TopologySkewed"
+	ClusterConditionRestarting                          = "Restarting"
 )
 
 type ClusterStatus struct {
@@ -54,17 +57,151 @@ type ClusterStatus struct {
 	// ServiceName is the LB service for accessing zookeeper nodes.
 	ServiceName string `json:"serviceName,omitempty"`
 
-	// ClientPort is the port for zookeeper client to access.
-	// It's the same on client LB service and zookeeper nodes.
+	// ClientPort is the port for zookeeper client to access on the client LB service.
+	// It may differ from the port of the zookeeper nodes when set by the service policy.
 	ClientPort int `json:"clientPort,omitempty"`
 
 	// Members are the zookeeper members in the cluster
 	Members MembersStatus `json:"members"`
+	// ExternalEndpoints are the addresses the members are reachable at from outside of
+	// the Kubernetes cluster, when external access is enabled.
+	ExternalEndpoints []ExternalEndpoint `json:"externalEndpoints,omitempty"`
+	// Topology is the number of running participants in each domain of the topology key,
+	// when the members are spread by a topology policy.
+	Topology map[string]int `json:"topology,omitempty"`
 	// CurrentVersion is the current cluster version
 	CurrentVersion string `json:"currentVersion"`
 	// TargetVersion is the version the cluster upgrading to.
 	// If the cluster is not upgrading, TargetVersion is empty.
 	TargetVersion string `json:"targetVersion"`
+
+	// UpgradeHistory records the latest upgrades and rollbacks of the cluster, oldest first.
+	UpgradeHistory []UpgradeRecord `json:"upgradeHistory,omitempty"`
+
+	// QuorumTLSPhase is how far the quorum traffic of the members has been moved to TLS.
+	QuorumTLSPhase QuorumTLSPhase `json:"quorumTLSPhase,omitempty"`
+
+	// QuorumSASLPhase is how far the members require quorum SASL authentication.
+	QuorumSASLPhase QuorumSASLPhase `json:"quorumSASLPhase,omitempty"`
+
+	// TLSCertificates are the certificates found in the TLS secrets of the cluster.
+	TLSCertificates []CertificateStatus `json:"tlsCertificates,omitempty"`
+
+	// Restore is the latest restore of the seed member from a backup, if any.
+	Restore *RestoreStatus `json:"restore,omitempty"`
+}
+
+type RestorePhase string
+
+const (
+	RestorePhaseRunning   RestorePhase = "Running"
+	RestorePhaseSucceeded RestorePhase = "Succeeded"
+	RestorePhaseFailed    RestorePhase = "Failed"
+)
+
+// RestoreStatus is the restore of the seed member from a backup. The seed member waits
+// in its restore container until the backup is extracted into its volumes.
+type RestoreStatus struct {
+	// Member is the seed member the backup is extracted into.
+	Member string `json:"member"`
+	// Path is the path of the backup archive.
+	Path string `json:"path"`
+	// Rebuild tells whether the cluster is rebuilt from the backup of its recovery
+	// policy, rather than created from the one of its restore policy.
+	Rebuild bool         `json:"rebuild,omitempty"`
+	Phase   RestorePhase `json:"phase"`
+	Reason  string       `json:"reason,omitempty"`
+}
+
+// IsRunning tells whether the backup is being extracted into the seed member.
+func (rs *RestoreStatus) IsRunning() bool {
+	return rs != nil && rs.Phase == RestorePhaseRunning
+}
+
+// CertificateStatus is the certificate stored under tls.crt in a TLS secret.
+type CertificateStatus struct {
+	Secret   string `json:"secret"`
+	NotAfter string `json:"notAfter"`
+}
+
+// QuorumTLSPhase is a step of the migration of the quorum traffic to TLS. Each step
+// is rolled out to all members before the next one starts, so that every member
+// can talk to every other member at any time.
+type QuorumTLSPhase string
+
+const (
+	// QuorumTLSPhaseNone means the members talk to each other in plaintext.
+	QuorumTLSPhaseNone QuorumTLSPhase = ""
+	// QuorumTLSPhasePortUnification means the members accept TLS and plaintext quorum
+	// connections, but still connect in plaintext.
+	QuorumTLSPhasePortUnification QuorumTLSPhase = "PortUnification"
+	// QuorumTLSPhaseSSLQuorumPortUnification means the members connect over TLS, but
+	// still accept plaintext quorum connections.
+	QuorumTLSPhaseSSLQuorumPortUnification QuorumTLSPhase = "SSLQuorumPortUnification"
+	// QuorumTLSPhaseSSLQuorum means the members only talk to each other over TLS.
+	QuorumTLSPhaseSSLQuorum QuorumTLSPhase = "SSLQuorum"
+)
+
+// Next returns the phase following p in the migration to TLS.
+func (p QuorumTLSPhase) Next() QuorumTLSPhase {
+	switch p {
+	case QuorumTLSPhaseNone:
+		return QuorumTLSPhasePortUnification
+	case QuorumTLSPhasePortUnification:
+		return QuorumTLSPhaseSSLQuorumPortUnification
+	default:
+		return QuorumTLSPhaseSSLQuorum
+	}
+}
+
+// QuorumSASLPhase is a step of requiring quorum SASL authentication. Each step is rolled
+// out to all members before the next one starts, so that a member never rejects the
+// quorum connections of a member that does not authenticate yet.
+type QuorumSASLPhase string
+
+const (
+	// QuorumSASLPhaseNone means the members authenticate to each other if they have a
+	// quorum secret, but do not require it.
+	QuorumSASLPhaseNone QuorumSASLPhase = ""
+	// QuorumSASLPhaseLearnerRequired means the members only connect to a leader as
+	// learners with authentication, but still accept unauthenticated learners.
+	QuorumSASLPhaseLearnerRequired QuorumSASLPhase = "LearnerRequired"
+	// QuorumSASLPhaseRequired means the members also reject unauthenticated learners.
+	QuorumSASLPhaseRequired QuorumSASLPhase = "Required"
+)
+
+// Toward returns the phase following p on the way to the target phase. The requirement
+// is lifted in the reverse order it is added in.
+func (p QuorumSASLPhase) Toward(target QuorumSASLPhase) QuorumSASLPhase {
+	switch {
+	case p == target:
+		return p
+	case p == QuorumSASLPhaseLearnerRequired:
+		return target
+	default:
+		return QuorumSASLPhaseLearnerRequired
+	}
+}
+
+type UpgradeOutcome string
+
+const (
+	UpgradeOutcomeInProgress UpgradeOutcome = "InProgress"
+	UpgradeOutcomeSucceeded  UpgradeOutcome = "Succeeded"
+	UpgradeOutcomeFailed     UpgradeOutcome = "Failed"
+	// UpgradeOutcomeSuperseded means the version was changed again before the upgrade finished.
+	UpgradeOutcomeSuperseded UpgradeOutcome = "Superseded"
+
+	maxUpgradeHistory = 10
+)
+
+// UpgradeRecord describes one upgrade or rollback of the cluster.
+type UpgradeRecord struct {
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	StartTime string         `json:"startTime"`
+	EndTime   string         `json:"endTime,omitempty"`
+	Outcome   UpgradeOutcome `json:"outcome"`
 }
 
 // ClusterCondition represents one current condition of an zookeeper cluster.
@@ -87,12 +224,30 @@ type ClusterCondition struct {
 	Message string `json:"message,omitempty"`
 }
 
+// ExternalEndpoint is the address a member is reachable at from outside of the
+// Kubernetes cluster.
+type ExternalEndpoint struct {
+	Member string `json:"member"`
+	// Host is the node address of a NodePort service, or the ingress of a LoadBalancer
+	// service. It is empty until the address is known.
+	Host string `json:"host,omitempty"`
+	// ClientPort is the plaintext client port, if any.
+	ClientPort int32 `json:"clientPort,omitempty"`
+	// SecureClientPort is the TLS client port, if any.
+	SecureClientPort int32 `json:"secureClientPort,omitempty"`
+}
+
 type MembersStatus struct {
 	// Ready are the zookeeper members that are ready to serve requests
 	// The member names are the same as the zookeeper pod names
 	Ready []string `json:"ready,omitempty"`
 	// Unready are the zookeeper members not ready to serve requests
 	Unready []string `json:"unready,omitempty"`
+	// ReadyObservers are the observers that are ready to serve requests. Observers are
+	// not part of Ready and Unready.
+	ReadyObservers []string `json:"readyObservers,omitempty"`
+	// UnreadyObservers are the observers not ready to serve requests
+	UnreadyObservers []string `json:"unreadyObservers,omitempty"`
 }
 
 func (cs *ClusterStatus) IsFailed() bool {
@@ -114,15 +269,48 @@ func (cs *ClusterStatus) Control() {
 	cs.ControlPaused = false
 }
 
+// UpgradeVersionTo starts the upgrade to version v, superseding the unfinished one if any.
+// Moving to the current version is no upgrade and is not recorded.
 func (cs *ClusterStatus) UpgradeVersionTo(v string) {
+	if cs.TargetVersion == v || (len(cs.TargetVersion) == 0 && cs.CurrentVersion == v) {
+		return
+	}
+	from := cs.CurrentVersion
+	if len(cs.TargetVersion) != 0 {
+		from = cs.TargetVersion
+	}
+	cs.finishUpgrade(UpgradeOutcomeSuperseded)
 	cs.TargetVersion = v
+
+	cs.UpgradeHistory = append(cs.UpgradeHistory, UpgradeRecord{
+		From:      from,
+		To:        v,
+		StartTime: time.Now().Format(time.RFC3339),
+		Outcome:   UpgradeOutcomeInProgress,
+	})
+	if len(cs.UpgradeHistory) > maxUpgradeHistory {
+		cs.UpgradeHistory = cs.UpgradeHistory[len(cs.UpgradeHistory)-maxUpgradeHistory:]
+	}
 }
 
 func (cs *ClusterStatus) SetVersion(v string) {
+	if len(cs.TargetVersion) != 0 {
+		cs.finishUpgrade(UpgradeOutcomeSucceeded)
+	}
 	cs.TargetVersion = ""
 	cs.CurrentVersion = v
 }
 
+// finishUpgrade records the outcome of the upgrade in progress, if any.
+func (cs *ClusterStatus) finishUpgrade(outcome UpgradeOutcome) {
+	n := len(cs.UpgradeHistory)
+	if n == 0 || cs.UpgradeHistory[n-1].Outcome != UpgradeOutcomeInProgress {
+		return
+	}
+	cs.UpgradeHistory[n-1].Outcome = outcome
+	cs.UpgradeHistory[n-1].EndTime = time.Now().Format(time.RFC3339)
+}
+
 func (cs *ClusterStatus) SetReason(r string) {
 	cs.Reason = r
 }
@@ -137,9 +325,9 @@ func (cs *ClusterStatus) SetScalingDownCondition(from, to int) {
 	cs.setClusterCondition(*c)
 }
 
-func (cs *ClusterStatus) SetRecoveringCondition() {
+func (cs *ClusterStatus) SetRecoveringCondition(msg string) {
 	c := newClusterCondition(ClusterConditionRecovering, v1.ConditionTrue,
-		"Disaster recovery", "Majority is down. Recovering from backup")
+		"Disaster recovery", "Majority is down. "+msg)
 	cs.setClusterCondition(*c)
 
 	cs.ClearCondition(ClusterConditionAvailable)
@@ -152,6 +340,72 @@ func (cs *ClusterStatus) SetUpgradingCondition(to string) {
 	cs.setClusterCondition(*c)
 }
 
+func (cs *ClusterStatus) SetRollingBackCondition(to string) {
+	c := newClusterCondition(ClusterConditionUpgrading, v1.ConditionTrue,
+		"Cluster rolling back", "rolling back to "+to)
+	cs.setClusterCondition(*c)
+}
+
+// SetUpgradeFailedCondition marks the upgrade to the given version as stopped.
+func (cs *ClusterStatus) SetUpgradeFailedCondition(to, reason, message string) {
+	c := newClusterCondition(ClusterConditionUpgrading, v1.ConditionFalse, reason,
+		fmt.Sprintf("upgrade to %s stopped: %s", to, message))
+	cs.setClusterCondition(*c)
+	cs.finishUpgrade(UpgradeOutcomeFailed)
+}
+
+// SetRestartFailedCondition marks the rolling restart of the members as stopped.
+func (cs *ClusterStatus) SetRestartFailedCondition(reason, message string) {
+	c := newClusterCondition(ClusterConditionRestarting, v1.ConditionFalse, reason,
+		"restart stopped: "+message)
+	cs.setClusterCondition(*c)
+}
+
+// SetRestartRefusedCondition reports that the rolling restart waits since the next member
+// cannot be restarted without losing the quorum. It returns false if the same refusal is
+// already reported.
+func (cs *ClusterStatus) SetRestartRefusedCondition(message string) bool {
+	c := newClusterCondition(ClusterConditionRestarting, v1.ConditionFalse, "Quorum at risk",
+		"restart waiting: "+message)
+	if _, cp := getClusterCondition(cs, ClusterConditionRestarting); cp != nil && cp.Reason == c.Reason && cp.Message == c.Message {
+		return false
+	}
+	cs.setClusterCondition(*c)
+	return true
+}
+
+// SetUpgradeRejectedCondition marks the upgrade to the given version as not allowed.
+func (cs *ClusterStatus) SetUpgradeRejectedCondition(to, message string) {
+	c := newClusterCondition(ClusterConditionUpgrading, v1.ConditionFalse, "Upgrade rejected",
+		fmt.Sprintf("upgrade to %s rejected: %s", to, message))
+	cs.setClusterCondition(*c)
+}
+
+// IsUpgradeFailed tells whether the upgrade to the given version was stopped.
+func (cs *ClusterStatus) IsUpgradeFailed(to string) bool {
+	_, c := getClusterCondition(cs, ClusterConditionUpgrading)
+	return c != nil && c.Status == v1.ConditionFalse && cs.TargetVersion == to
+}
+
+// SetSpecRejectedCondition reports that the latest spec is invalid and the cluster
+// keeps running with the previous one. It returns false if the same rejection is
+// already reported.
+func (cs *ClusterStatus) SetSpecRejectedCondition(message string) bool {
+	if _, cp := getClusterCondition(cs, ClusterConditionSpecRejected); cp != nil && cp.Message == message {
+		return false
+	}
+	c := newClusterCondition(ClusterConditionSpecRejected, v1.ConditionTrue, "Invalid spec", message)
+	cs.setClusterCondition(*c)
+	return true
+}
+
+// SetTopologySkewedCondition warns that a single domain holds a majority of the members.
+func (cs *ClusterStatus) SetTopologySkewedCondition(domain string, members, size int) {
+	c := newClusterCondition(ClusterConditionTopologySkewed, v1.ConditionTrue, "Majority in one domain",
+		fmt.Sprintf("domain %s holds %d of %d members, losing it loses the quorum", domain, members, size))
+	cs.setClusterCondition(*c)
+}
+
 func (cs *ClusterStatus) SetReadyCondition() {
 	c := newClusterCondition(ClusterConditionAvailable, v1.ConditionTrue, "Cluster available", "")
 	cs.setClusterCondition(*c)
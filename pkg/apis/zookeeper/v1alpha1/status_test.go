@@ -0,0 +1,62 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package v1alpha1
+
+import "testing"
+
+func TestUpgradeVersionTo(t *testing.T) {
+	cs := &ClusterStatus{CurrentVersion: "3.5.3-beta"}
+
+	// An image change keeps the version, it is no upgrade.
+	cs.UpgradeVersionTo("3.5.3-beta")
+	if len(cs.TargetVersion) != 0 || len(cs.UpgradeHistory) != 0 {
+		t.Fatalf("expected no upgrade to the current version, got target=%q, history=%+v", cs.TargetVersion, cs.UpgradeHistory)
+	}
+
+	cs.UpgradeVersionTo("3.5.4-beta")
+	if cs.TargetVersion != "3.5.4-beta" || len(cs.UpgradeHistory) != 1 {
+		t.Fatalf("expected an upgrade to 3.5.4-beta, got target=%q, history=%+v", cs.TargetVersion, cs.UpgradeHistory)
+	}
+	if r := cs.UpgradeHistory[0]; r.From != "3.5.3-beta" || r.To != "3.5.4-beta" || r.Outcome != UpgradeOutcomeInProgress {
+		t.Errorf("unexpected upgrade record: %+v", r)
+	}
+
+	// Going back to the current version during an upgrade supersedes it.
+	cs.UpgradeVersionTo("3.5.3-beta")
+	if cs.TargetVersion != "3.5.3-beta" || len(cs.UpgradeHistory) != 2 || cs.UpgradeHistory[0].Outcome != UpgradeOutcomeSuperseded {
+		t.Errorf("expected the rollback to supersede the upgrade, got target=%q, history=%+v", cs.TargetVersion, cs.UpgradeHistory)
+	}
+
+	cs.SetVersion("3.5.3-beta")
+	if len(cs.TargetVersion) != 0 || cs.UpgradeHistory[1].Outcome != UpgradeOutcomeSucceeded {
+		t.Errorf("expected the rollback to succeed, got target=%q, history=%+v", cs.TargetVersion, cs.UpgradeHistory)
+	}
+}
+
+func TestQuorumSASLPhaseToward(t *testing.T) {
+	tests := []struct {
+		from, target, want QuorumSASLPhase
+	}{
+		{from: QuorumSASLPhaseNone, target: QuorumSASLPhaseRequired, want: QuorumSASLPhaseLearnerRequired},
+		{from: QuorumSASLPhaseLearnerRequired, target: QuorumSASLPhaseRequired, want: QuorumSASLPhaseRequired},
+		{from: QuorumSASLPhaseRequired, target: QuorumSASLPhaseRequired, want: QuorumSASLPhaseRequired},
+		{from: QuorumSASLPhaseRequired, target: QuorumSASLPhaseNone, want: QuorumSASLPhaseLearnerRequired},
+		{from: QuorumSASLPhaseLearnerRequired, target: QuorumSASLPhaseNone, want: QuorumSASLPhaseNone},
+	}
+	for _, tt := range tests {
+		if get := tt.from.Toward(tt.target); get != tt.want {
+			t.Errorf("%q toward %q: get=%q, want=%q", tt.from, tt.target, get, tt.want)
+		}
+	}
+}
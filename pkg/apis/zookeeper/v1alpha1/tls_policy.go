@@ -0,0 +1,127 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+	"time"
+)
+
+// TLSPolicy defines the TLS settings of the zookeeper cluster.
+type TLSPolicy struct {
+	// Static enables the user to generate static certificates and keys,
+	// put them into Kubernetes secrets, and specify them here.
+	Static *StaticTLS `json:"static,omitempty"`
+}
+
+type StaticTLS struct {
+	// Member contains the secrets holding the TLS material of each zookeeper member.
+	Member *MemberSecret `json:"member,omitempty"`
+
+	// OperatorSecret is the secret holding the client certificate the operator uses to
+	// talk to the secure client port, under the keys "tls.crt", "tls.key" and "ca.crt".
+	// It is required if the plain client port is disabled.
+	OperatorSecret string `json:"operatorSecret,omitempty"`
+
+	// DisablePlainClientPort makes the plain client port listen on localhost only,
+	// so that clients outside the member pods have to use the secure client port.
+	DisablePlainClientPort bool `json:"disablePlainClientPort,omitempty"`
+
+	// ExpiryWarningInDays is how many days before a certificate of the TLS secrets expires
+	// the operator starts emitting warning events. Defaults to 30.
+	ExpiryWarningInDays int `json:"expiryWarningInDays,omitempty"`
+
+	// ReloadPeerCerts makes the members reload the keystore and truststore of the peer
+	// secret when it changes, instead of restarting them. Requires Zookeeper 3.5.5 or later.
+	ReloadPeerCerts bool `json:"reloadPeerCerts,omitempty"`
+}
+
+// DefaultCertExpiryWarningInDays is how many days before a certificate expires the
+// operator warns about it by default.
+const DefaultCertExpiryWarningInDays = 30
+
+// MemberClientPort is the port the members serve clients on in plaintext.
+const MemberClientPort = 2181
+
+// SecureClientPort is the port the members serve clients on over TLS.
+const SecureClientPort = 2281
+
+// QuorumTLSMinVersion is the first Zookeeper version supporting TLS on the quorum and
+// election ports.
+const QuorumTLSMinVersion = "3.5.5"
+
+type MemberSecret struct {
+	// ServerSecret is the secret holding the keystore and truststore of the secure client
+	// port, under the keys "keystore.jks" and "truststore.jks", and their passwords, under
+	// the keys "keystore.password" and "truststore.password".
+	ServerSecret string `json:"serverSecret,omitempty"`
+
+	// PeerSecret is the secret holding the keystore and truststore the members talk to each
+	// other with on the quorum and election ports, under the same keys as ServerSecret.
+	// Requires Zookeeper 3.5.5 or later.
+	// Setting it on a running cluster migrates the quorum traffic to TLS without losing
+	// the quorum. It cannot be removed once set.
+	PeerSecret string `json:"peerSecret,omitempty"`
+}
+
+func (tp *TLSPolicy) Validate() error {
+	if tp.Static == nil {
+		return nil
+	}
+	st := tp.Static
+	if st.Member == nil || (len(st.Member.ServerSecret) == 0 && len(st.Member.PeerSecret) == 0) {
+		return errors.New("spec: tls static member serverSecret or peerSecret must be set")
+	}
+	if st.DisablePlainClientPort && len(st.Member.ServerSecret) == 0 {
+		return errors.New("spec: tls static member serverSecret must be set if the plain client port is disabled")
+	}
+	if st.ExpiryWarningInDays < 0 {
+		return errors.New("spec: tls static expiryWarningInDays must not be negative")
+	}
+	if st.DisablePlainClientPort && len(st.OperatorSecret) == 0 {
+		return errors.New("spec: tls static operatorSecret must be set if the plain client port is disabled")
+	}
+	return nil
+}
+
+// IsSecureClient tells whether the members serve clients over TLS.
+func (tp *TLSPolicy) IsSecureClient() bool {
+	if tp == nil || tp.Static == nil || tp.Static.Member == nil {
+		return false
+	}
+	return len(tp.Static.Member.ServerSecret) != 0
+}
+
+// IsPlainClientPortDisabled tells whether the plain client port only listens on localhost.
+func (tp *TLSPolicy) IsPlainClientPortDisabled() bool {
+	return tp.IsSecureClient() && tp.Static.DisablePlainClientPort
+}
+
+// IsSecurePeer tells whether the members talk to each other over TLS.
+func (tp *TLSPolicy) IsSecurePeer() bool {
+	if tp == nil || tp.Static == nil || tp.Static.Member == nil {
+		return false
+	}
+	return len(tp.Static.Member.PeerSecret) != 0
+}
+
+// CertExpiryWarning returns how long before a certificate expires the operator warns about it.
+func (tp *TLSPolicy) CertExpiryWarning() time.Duration {
+	days := DefaultCertExpiryWarningInDays
+	if tp != nil && tp.Static != nil && tp.Static.ExpiryWarningInDays > 0 {
+		days = tp.Static.ExpiryWarningInDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
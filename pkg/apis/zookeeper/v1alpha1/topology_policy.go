@@ -0,0 +1,34 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"errors"
+)
+
+// TopologyPolicy spreads the members evenly over the domains of a topology key, such as
+// zones or racks, so that losing a domain does not lose the quorum.
+type TopologyPolicy struct {
+	// TopologyKey is the node label whose values are the domains the members are spread
+	// over, e.g. failure-domain.beta.kubernetes.io/zone.
+	TopologyKey string `json:"topologyKey"`
+}
+
+func (tp *TopologyPolicy) Validate() error {
+	if len(tp.TopologyKey) == 0 {
+		return errors.New("spec: topology topologyKey must be set")
+	}
+	return nil
+}
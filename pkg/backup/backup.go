@@ -0,0 +1,271 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/backup/writer"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/generated/clientset/versioned"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+
+	storePodTimeout = 2 * time.Minute
+)
+
+type Config struct {
+	KubeCli        kubernetes.Interface
+	RestConfig     *rest.Config
+	ZookeeperCRCli versioned.Interface
+}
+
+// Backup runs the backups of a ZookeeperBackup resource: once, or periodically when
+// it has a backup policy.
+type Backup struct {
+	logger *logrus.Entry
+
+	config Config
+	backup *api.ZookeeperBackup
+
+	stopCh chan struct{}
+}
+
+func New(config Config, b *api.ZookeeperBackup) *Backup {
+	bk := &Backup{
+		logger: logrus.WithField("pkg", "backup").WithField("backup-name", b.Name),
+		config: config,
+		backup: b,
+		stopCh: make(chan struct{}),
+	}
+	go bk.run()
+	return bk
+}
+
+// Delete stops the backups. A backup in progress runs to completion.
+func (bk *Backup) Delete() {
+	close(bk.stopCh)
+}
+
+func (bk *Backup) run() {
+	policy := bk.backup.Spec.BackupPolicy
+	if policy == nil {
+		// A one-shot backup is never retried. Recreate the resource to back up again.
+		if len(bk.backup.Status.Time) == 0 && len(bk.backup.Status.Reason) == 0 {
+			bk.backupOnce()
+		}
+		return
+	}
+
+	interval := time.Duration(policy.BackupIntervalInSecond) * time.Second
+	var wait time.Duration
+	if last, err := time.Parse(time.RFC3339, bk.backup.Status.Time); err == nil {
+		wait = interval - time.Since(last)
+	}
+	for {
+		if wait > 0 {
+			select {
+			case <-bk.stopCh:
+				return
+			case <-time.After(wait):
+			}
+		}
+		select {
+		case <-bk.stopCh:
+			return
+		default:
+		}
+		bk.backupOnce()
+		wait = interval
+	}
+}
+
+func (bk *Backup) backupOnce() {
+	status := bk.backup.Status
+	bm, basePath, err := bk.newBackupManager()
+	if err == nil {
+		var st *api.BackupStatus
+		st, err = bm.SaveSnap(basePath)
+		if err == nil {
+			status = *st
+		}
+	}
+	if err != nil {
+		bk.logger.Errorf("backup failed: %v", err)
+		// Keep the details of the last successful backup.
+		status.Succeeded = false
+		status.Reason = err.Error()
+	}
+	if uerr := bk.updateStatus(status); uerr != nil {
+		bk.logger.Errorf("failed to update backup status: %v", uerr)
+	}
+
+	var event *v1.Event
+	if err != nil {
+		event = k8sutil.BackupFailedEvent(err.Error(), bk.backup)
+	} else {
+		event = k8sutil.BackupSucceededEvent(bk.backup)
+	}
+	if _, eerr := bk.config.KubeCli.CoreV1().Events(bk.backup.Namespace).Create(event); eerr != nil {
+		bk.logger.Errorf("failed to create backup event: %v", eerr)
+	}
+
+	if err == nil && bk.backup.Spec.BackupPolicy != nil && bk.backup.Spec.BackupPolicy.MaxBackups > 0 {
+		if err := bm.EnsureMaxBackups(basePath, bk.backup.Spec.BackupPolicy.MaxBackups); err != nil {
+			bk.logger.Warningf("failed to delete old backups: %v", err)
+		}
+	}
+}
+
+// newBackupManager returns a backup manager writing to the storage of the backup, and
+// the base path of the backups in it.
+func (bk *Backup) newBackupManager() (*BackupManager, string, error) {
+	spec := bk.backup.Spec
+	ns := bk.backup.Namespace
+
+	var w writer.Writer
+	var basePath string
+	switch spec.StorageType {
+	case api.BackupStorageTypeS3:
+		sess, err := newS3Session(bk.config.KubeCli, ns, spec.S3)
+		if err != nil {
+			return nil, "", err
+		}
+		w = writer.NewS3Writer(sess)
+		basePath = spec.S3.Path
+	case api.BackupStorageTypePersistentVolume:
+		podName := k8sutil.BackupStorePodName(bk.backup.Name)
+		err := ensureStorePod(bk.config.KubeCli, ns, podName, spec.PV.ClaimName, bk.backup.AsOwner())
+		if err != nil {
+			return nil, "", err
+		}
+		w = writer.NewPVWriter(bk.config.RestConfig, bk.config.KubeCli, ns, podName)
+		basePath = spec.PV.Path
+	default:
+		return nil, "", fmt.Errorf("unknown storage type (%s)", spec.StorageType)
+	}
+	return NewBackupManager(bk.config.RestConfig, bk.config.KubeCli, ns, spec.ClusterName, w), basePath, nil
+}
+
+// updateStatus writes the status on the latest version of the resource, so that spec
+// changes made meanwhile are kept.
+func (bk *Backup) updateStatus(status api.BackupStatus) error {
+	cli := bk.config.ZookeeperCRCli.ZookeeperV1alpha1().ZookeeperBackups(bk.backup.Namespace)
+	latest, err := cli.Get(bk.backup.Name, metav1.GetOptions{})
+	if err != nil {
+		bk.backup.Status = status
+		return err
+	}
+	latest.Status = status
+	updated, err := cli.Update(latest)
+	if err != nil {
+		bk.backup.Status = status
+		return err
+	}
+	bk.backup.Status = updated.Status
+	return nil
+}
+
+// colocateStorePod schedules the store pod on the node of the other store pods mounting
+// its claim, e.g. the backup store pod of a claim a cluster is restored from. A
+// ReadWriteOnce volume cannot be mounted on two nodes at once.
+func colocateStorePod(kubecli kubernetes.Interface, ns string, pod *v1.Pod) error {
+	claimName := k8sutil.BackupStoreClaim(pod)
+	pods, err := kubecli.CoreV1().Pods(ns).List(k8sutil.BackupStoreListOpt())
+	if err != nil {
+		return fmt.Errorf("failed to list store pods: %v", err)
+	}
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if p.Name == pod.Name || p.DeletionTimestamp != nil || k8sutil.BackupStoreClaim(p) != claimName {
+			continue
+		}
+		if len(p.Spec.NodeName) == 0 {
+			return fmt.Errorf("store pod (%s) mounting claim (%s) is not scheduled yet", p.Name, claimName)
+		}
+		pod.Spec.NodeName = p.Spec.NodeName
+		return nil
+	}
+	return nil
+}
+
+// ensureStorePod creates the pod mounting the persistent volume claim of the backups
+// if it does not exist yet.
+func ensureStorePod(kubecli kubernetes.Interface, ns, podName, claimName string, owner metav1.OwnerReference) error {
+	pod, err := kubecli.CoreV1().Pods(ns).Get(podName, metav1.GetOptions{})
+	if err == nil {
+		if k8sutil.BackupStoreClaim(pod) != claimName {
+			// The claim changed, the pod is recreated on the next attempt.
+			err = kubecli.CoreV1().Pods(ns).Delete(podName, k8sutil.CascadeDeleteOptions(0))
+			if err != nil {
+				return fmt.Errorf("failed to delete backup store pod (%s): %v", podName, err)
+			}
+			return fmt.Errorf("backup store pod (%s) mounts an old claim, it is being recreated", podName)
+		}
+		if pod.Status.Phase != v1.PodRunning {
+			return fmt.Errorf("backup store pod (%s) is not running: %s", podName, pod.Status.Phase)
+		}
+		return nil
+	}
+	if !k8sutil.IsKubernetesResourceNotFoundError(err) {
+		return fmt.Errorf("failed to get backup store pod (%s): %v", podName, err)
+	}
+
+	pod = k8sutil.NewBackupStorePod(podName, claimName, owner)
+	if err := colocateStorePod(kubecli, ns, pod); err != nil {
+		return err
+	}
+	if _, err := k8sutil.CreateAndWaitPod(kubecli, ns, pod, storePodTimeout); err != nil {
+		return fmt.Errorf("failed to create backup store pod (%s): %v", podName, err)
+	}
+	return nil
+}
+
+func newS3Session(kubecli kubernetes.Interface, ns string, s3 *api.S3BackupSource) (*session.Session, error) {
+	secret, err := kubecli.CoreV1().Secrets(ns).Get(s3.AWSSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS secret (%s): %v", s3.AWSSecret, err)
+	}
+	region := s3.Region
+	if len(region) == 0 {
+		region = defaultS3Region
+	}
+	cfg := aws.NewConfig().
+		WithRegion(region).
+		WithS3ForcePathStyle(s3.ForcePathStyle).
+		WithCredentials(credentials.NewStaticCredentials(
+			string(secret.Data[api.AWSAccessKeyID]), string(secret.Data[api.AWSSecretAccessKey]), ""))
+	if len(s3.Endpoint) > 0 {
+		cfg = cfg.WithEndpoint(s3.Endpoint)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 session: %v", err)
+	}
+	return sess, nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/backup/writer"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	backupFileSuffix = ".tar.gz"
+	backupTimeFormat = "20060102T150405Z"
+
+	// listScript lists the snapshots and the transaction logs of a member, separated by
+	// a line holding only "---".
+	listScript = `ls -1 "${ZOO_DATA_DIR:-/data}/version-2"; echo ---; ls -1 "${ZOO_DATA_LOG_DIR:-/datalog}/version-2"`
+
+	// tarScript archives the given files of the data and the transaction log directories
+	// under data/ and datalog/, whatever the directories of the member are.
+	tarScript = `set -e
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+ln -s "${ZOO_DATA_DIR:-/data}" "$dir/data"
+ln -s "${ZOO_DATA_LOG_DIR:-/datalog}" "$dir/datalog"
+cd "$dir"
+tar czhf - "$@"`
+)
+
+// BackupManager takes backups of a zookeeper cluster.
+type BackupManager struct {
+	logger *logrus.Entry
+
+	restcfg     *rest.Config
+	kubecli     kubernetes.Interface
+	namespace   string
+	clusterName string
+	writer      writer.Writer
+}
+
+func NewBackupManager(restcfg *rest.Config, kubecli kubernetes.Interface, ns, clusterName string, w writer.Writer) *BackupManager {
+	return &BackupManager{
+		logger:      logrus.WithField("pkg", "backup").WithField("cluster-name", clusterName),
+		restcfg:     restcfg,
+		kubecli:     kubecli,
+		namespace:   ns,
+		clusterName: clusterName,
+		writer:      w,
+	}
+}
+
+// SaveSnap archives a snapshot of a ready member together with the transaction logs
+// following it, and writes the archive under basePath.
+func (bm *BackupManager) SaveSnap(basePath string) (*api.BackupStatus, error) {
+	pod, err := bm.readyMember()
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	err = k8sutil.ExecInContainer(bm.restcfg, bm.kubecli, bm.namespace, pod.Name, "zookeeper", []string{"sh", "-c", listScript}, nil, &out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the data of member (%s): %v", pod.Name, err)
+	}
+	dataFiles, logFiles := splitListing(out.String())
+	snap, logs, zxid, err := backupFiles(dataFiles, logFiles)
+	if err != nil {
+		return nil, fmt.Errorf("member (%s): %v", pod.Name, err)
+	}
+
+	args := []string{"sh", "-c", tarScript, "sh", path.Join("data/version-2", snap)}
+	for _, l := range logs {
+		args = append(args, path.Join("datalog/version-2", l))
+	}
+
+	now := time.Now().UTC()
+	p := path.Join(basePath, backupName(bm.clusterName, now, zxid))
+	bm.logger.Infof("backing up snapshot %s and %d transaction logs of member (%s) to %s", snap, len(logs), pod.Name, p)
+
+	pr, pw := io.Pipe()
+	execErr := make(chan error, 1)
+	go func() {
+		err := k8sutil.ExecInContainer(bm.restcfg, bm.kubecli, bm.namespace, pod.Name, "zookeeper", args, nil, pw)
+		pw.CloseWithError(err)
+		execErr <- err
+	}()
+	size, err := bm.writer.Write(p, pr)
+	pr.Close()
+	if eerr := <-execErr; eerr != nil && err == nil {
+		// The writer may have stored a truncated archive.
+		if derr := bm.writer.Delete(p); derr != nil {
+			bm.logger.Warningf("failed to delete incomplete backup: %v", derr)
+		}
+		err = eerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.BackupStatus{
+		Succeeded: true,
+		Path:      p,
+		Member:    pod.Name,
+		Size:      size,
+		Zxid:      strconv.FormatUint(zxid, 16),
+		Time:      now.Format(time.RFC3339),
+	}, nil
+}
+
+// EnsureMaxBackups deletes the oldest backups of the cluster under basePath beyond max.
+func (bm *BackupManager) EnsureMaxBackups(basePath string, max int) error {
+	paths, err := bm.writer.List(basePath)
+	if err != nil {
+		return err
+	}
+	for _, p := range backupsToDelete(paths, bm.clusterName, max) {
+		bm.logger.Infof("deleting old backup %s", p)
+		if err := bm.writer.Delete(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readyMember returns a running and ready member of the cluster.
+func (bm *BackupManager) readyMember() (*v1.Pod, error) {
+	podList, err := bm.kubecli.CoreV1().Pods(bm.namespace).List(k8sutil.ClusterListOpt(bm.clusterName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods of cluster (%s): %v", bm.clusterName, err)
+	}
+	sort.Slice(podList.Items, func(i, j int) bool { return podList.Items[i].Name < podList.Items[j].Name })
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.DeletionTimestamp == nil && pod.Status.Phase == v1.PodRunning && k8sutil.IsPodReady(pod) {
+			return pod, nil
+		}
+	}
+	return nil, fmt.Errorf("no ready member found in cluster (%s)", bm.clusterName)
+}
+
+// splitListing splits the output of listScript into the files of the data directory and
+// the files of the transaction log directory.
+func splitListing(out string) ([]string, []string) {
+	var data, logs []string
+	inLogs := false
+	for _, f := range strings.Fields(out) {
+		switch {
+		case f == "---":
+			inLogs = true
+		case inLogs:
+			logs = append(logs, f)
+		default:
+			data = append(data, f)
+		}
+	}
+	return data, logs
+}
+
+// backupFiles selects the snapshot to back up and the transaction logs needed to replay
+// the transactions from it, and returns the zxid of the snapshot.
+// The newest snapshot may still be being written, so the one before it is taken when
+// there are several. The logs following it bring the backup to the same point.
+func backupFiles(dataFiles, logFiles []string) (string, []string, uint64, error) {
+	snaps := filesByZxid(dataFiles, "snapshot.")
+	if len(snaps) == 0 {
+		return "", nil, 0, fmt.Errorf("no snapshot found")
+	}
+	snap := snaps[len(snaps)-1]
+	if len(snaps) > 1 {
+		snap = snaps[len(snaps)-2]
+	}
+
+	// A log file is named after its first zxid, so the transactions following the
+	// snapshot start in the last log whose zxid is not greater than the snapshot.
+	var logs []string
+	for _, l := range filesByZxid(logFiles, "log.") {
+		if l.zxid <= snap.zxid {
+			logs = logs[:0]
+		}
+		logs = append(logs, l.name)
+	}
+	return snap.name, logs, snap.zxid, nil
+}
+
+type zxidFile struct {
+	name string
+	zxid uint64
+}
+
+// filesByZxid returns the files with the given prefix followed by a hexadecimal zxid,
+// sorted by zxid.
+func filesByZxid(files []string, prefix string) []zxidFile {
+	var zfs []zxidFile
+	for _, f := range files {
+		if !strings.HasPrefix(f, prefix) {
+			continue
+		}
+		hex := strings.TrimPrefix(f, prefix)
+		if i := strings.Index(hex, "."); i >= 0 {
+			hex = hex[:i]
+		}
+		zxid, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			continue
+		}
+		zfs = append(zfs, zxidFile{name: f, zxid: zxid})
+	}
+	sort.Slice(zfs, func(i, j int) bool { return zfs[i].zxid < zfs[j].zxid })
+	return zfs
+}
+
+// backupName returns the file name of a backup. Backups of a cluster sort by time.
+func backupName(clusterName string, t time.Time, zxid uint64) string {
+	return fmt.Sprintf("%s_%s_%x%s", clusterName, t.Format(backupTimeFormat), zxid, backupFileSuffix)
+}
+
+// backupsToDelete returns the oldest backups of the cluster beyond max. Other files
+// are left alone.
+func backupsToDelete(paths []string, clusterName string, max int) []string {
+	if max <= 0 {
+		return nil
+	}
+	var backups []string
+	for _, p := range paths {
+		name := path.Base(p)
+		if strings.HasPrefix(name, clusterName+"_") && strings.HasSuffix(name, backupFileSuffix) {
+			backups = append(backups, p)
+		}
+	}
+	if len(backups) <= max {
+		return nil
+	}
+	sort.Slice(backups, func(i, j int) bool { return path.Base(backups[i]) < path.Base(backups[j]) })
+	return backups[:len(backups)-max]
+}
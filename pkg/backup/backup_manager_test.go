@@ -0,0 +1,95 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackupFiles(t *testing.T) {
+	tests := []struct {
+		data, logs []string
+
+		wantSnap string
+		wantLogs []string
+		wantZxid uint64
+	}{{
+		data:     []string{"acceptedEpoch", "currentEpoch", "snapshot.0"},
+		logs:     []string{"log.1"},
+		wantSnap: "snapshot.0",
+		wantLogs: []string{"log.1"},
+		wantZxid: 0,
+	}, {
+		// The newest snapshot may be incomplete, the logs cover it.
+		data:     []string{"snapshot.100000000", "snapshot.10000002a", "snapshot.2000000c8"},
+		logs:     []string{"log.100000001", "log.100000020", "log.200000001"},
+		wantSnap: "snapshot.10000002a",
+		wantLogs: []string{"log.100000020", "log.200000001"},
+		wantZxid: 0x10000002a,
+	}, {
+		data:     []string{"snapshot.0", "snapshot.a"},
+		logs:     []string{"log.a", "log.b"},
+		wantSnap: "snapshot.0",
+		wantLogs: []string{"log.a", "log.b"},
+		wantZxid: 0,
+	}}
+	for i, tt := range tests {
+		snap, logs, zxid, err := backupFiles(tt.data, tt.logs)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if snap != tt.wantSnap || zxid != tt.wantZxid || !reflect.DeepEqual(logs, tt.wantLogs) {
+			t.Errorf("#%d: got (%s, %v, %x), want (%s, %v, %x)", i, snap, logs, zxid, tt.wantSnap, tt.wantLogs, tt.wantZxid)
+		}
+	}
+
+	if _, _, _, err := backupFiles([]string{"myid"}, nil); err == nil {
+		t.Error("expected an error without snapshot")
+	}
+}
+
+func TestSplitListing(t *testing.T) {
+	data, logs := splitListing("snapshot.0\nsnapshot.a\n---\nlog.1\n")
+	if !reflect.DeepEqual(data, []string{"snapshot.0", "snapshot.a"}) || !reflect.DeepEqual(logs, []string{"log.1"}) {
+		t.Errorf("got (%v, %v)", data, logs)
+	}
+}
+
+func TestBackupsToDelete(t *testing.T) {
+	t1 := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+	paths := []string{
+		"bucket/zk/" + backupName("zk", t3, 0x30),
+		"bucket/zk/" + backupName("zk", t1, 0x10),
+		"bucket/zk/" + backupName("zk-other", t1, 0x10),
+		"bucket/zk/notes.txt",
+		"bucket/zk/" + backupName("zk", t2, 0x20),
+	}
+
+	got := backupsToDelete(paths, "zk", 2)
+	want := []string{"bucket/zk/zk_20180301T100000Z_10.tar.gz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := backupsToDelete(paths, "zk", 0); got != nil {
+		t.Errorf("expected no deletion without limit, got %v", got)
+	}
+	if got := backupsToDelete(paths, "zk", 3); got != nil {
+		t.Errorf("expected no deletion under limit, got %v", got)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"testing"
+
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestColocateStorePod(t *testing.T) {
+	ns := metav1.NamespaceDefault
+	owner := metav1.OwnerReference{}
+	backupPod := k8sutil.NewBackupStorePod(k8sutil.BackupStorePodName("daily"), "backups", owner)
+	backupPod.Spec.NodeName = "node-a"
+	otherPod := k8sutil.NewBackupStorePod(k8sutil.BackupStorePodName("hourly"), "other-backups", owner)
+	otherPod.Spec.NodeName = "node-b"
+	for _, p := range []*v1.Pod{backupPod, otherPod} {
+		p.Namespace = ns
+	}
+	kubecli := fake.NewSimpleClientset(backupPod, otherPod)
+
+	tests := []struct {
+		claim string
+		wNode string
+	}{
+		{claim: "backups", wNode: "node-a"},
+		{claim: "other-backups", wNode: "node-b"},
+		{claim: "unused", wNode: ""},
+	}
+	for _, tt := range tests {
+		pod := k8sutil.NewBackupStorePod(k8sutil.RestoreStorePodName("example"), tt.claim, owner)
+		if err := colocateStorePod(kubecli, ns, pod); err != nil {
+			t.Fatalf("%s: %v", tt.claim, err)
+		}
+		if pod.Spec.NodeName != tt.wNode {
+			t.Errorf("%s: node get=%q, want=%q", tt.claim, pod.Spec.NodeName, tt.wNode)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"io"
+
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+type pvReader struct {
+	restcfg   *rest.Config
+	kubecli   kubernetes.Interface
+	namespace string
+	podName   string
+}
+
+// NewPVReader returns a reader of the backups on the persistent volume mounted by the
+// given backup store pod. The paths of the reader are relative to the volume.
+func NewPVReader(restcfg *rest.Config, kubecli kubernetes.Interface, ns, podName string) Reader {
+	return &pvReader{
+		restcfg:   restcfg,
+		kubecli:   kubecli,
+		namespace: ns,
+		podName:   podName,
+	}
+}
+
+// Open streams the backup out of the backup store pod. A failure of the stream is
+// returned by the reads.
+func (r *pvReader) Open(p string) (io.ReadCloser, error) {
+	fp, err := k8sutil.BackupStorePath(p)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	cmd := []string{"cat", fp}
+	go func() {
+		err := k8sutil.ExecInContainer(r.restcfg, r.kubecli, r.namespace, r.podName, k8sutil.BackupStoreContainer, cmd, nil, pw)
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
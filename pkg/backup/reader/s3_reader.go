@@ -0,0 +1,50 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type s3Reader struct {
+	s3 *s3.S3
+}
+
+// NewS3Reader returns a reader of the backups of an S3 compatible object store.
+// The paths of the reader are in the format "<bucket>/<key>".
+func NewS3Reader(sess *session.Session) Reader {
+	return &s3Reader{s3: s3.New(sess)}
+}
+
+func (r *s3Reader) Open(path string) (io.ReadCloser, error) {
+	i := strings.Index(path, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid S3 path (%s): must be in the format <bucket>/<key>", path)
+	}
+	resp, err := r.s3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(path[:i]),
+		Key:    aws.String(path[i+1:]),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup (%s): %v", path, err)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/backup/reader"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/retryutil"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// restoreContainerTimeout is how long the restore container of the seed member has to
+// start, which includes pulling the images and binding the volumes of the pod.
+var restoreContainerTimeout = 5 * time.Minute
+
+// RestoreMember extracts the backup of the restore policy into the given member of the
+// cluster, whose pod waits for it in its restore container.
+func RestoreMember(config Config, cl *api.ZookeeperCluster, rp *api.RestorePolicy, podName string) error {
+	ns := cl.Namespace
+
+	var r reader.Reader
+	var p string
+	switch rp.StorageType {
+	case api.BackupStorageTypeS3:
+		sess, err := newS3Session(config.KubeCli, ns, rp.S3)
+		if err != nil {
+			return err
+		}
+		r = reader.NewS3Reader(sess)
+		p = rp.S3.Path
+	case api.BackupStorageTypePersistentVolume:
+		storePod := k8sutil.RestoreStorePodName(cl.Name)
+		if err := ensureStorePod(config.KubeCli, ns, storePod, rp.PV.ClaimName, cl.AsOwner()); err != nil {
+			return err
+		}
+		defer func() {
+			err := config.KubeCli.CoreV1().Pods(ns).Delete(storePod, k8sutil.CascadeDeleteOptions(0))
+			if err != nil && !k8sutil.IsKubernetesResourceNotFoundError(err) {
+				logrus.Warningf("failed to delete restore store pod (%s): %v", storePod, err)
+			}
+		}()
+		r = reader.NewPVReader(config.RestConfig, config.KubeCli, ns, storePod)
+		p = rp.PV.Path
+	default:
+		return fmt.Errorf("unknown storage type (%s)", rp.StorageType)
+	}
+
+	if err := waitRestoreContainer(config.KubeCli, ns, podName); err != nil {
+		return err
+	}
+	rc, err := r.Open(p)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	err = k8sutil.ExecInContainer(config.RestConfig, config.KubeCli, ns, podName, k8sutil.RestoreContainer, k8sutil.RestoreCommand(), rc, ioutil.Discard)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup (%s) into member (%s): %v", p, podName, err)
+	}
+	return nil
+}
+
+// LatestRestorePolicy returns the restore policy of the latest successful backup of the
+// given ZookeeperBackup.
+func LatestRestorePolicy(b *api.ZookeeperBackup) (*api.RestorePolicy, error) {
+	if len(b.Status.Path) == 0 {
+		return nil, fmt.Errorf("backup (%s) has no successful backup yet", b.Name)
+	}
+	rp := &api.RestorePolicy{StorageType: b.Spec.StorageType}
+	switch {
+	case b.Spec.StorageType == api.BackupStorageTypeS3 && b.Spec.S3 != nil:
+		s3 := *b.Spec.S3
+		s3.Path = b.Status.Path
+		rp.S3 = &s3
+	case b.Spec.StorageType == api.BackupStorageTypePersistentVolume && b.Spec.PV != nil:
+		pv := *b.Spec.PV
+		pv.Path = b.Status.Path
+		rp.PV = &pv
+	default:
+		return nil, fmt.Errorf("backup (%s) has an invalid storage", b.Name)
+	}
+	return rp, nil
+}
+
+// waitRestoreContainer waits until the restore container of the pod is running.
+func waitRestoreContainer(kubecli kubernetes.Interface, ns, podName string) error {
+	interval := 5 * time.Second
+	err := retryutil.Retry(interval, int(restoreContainerTimeout/interval), func() (bool, error) {
+		pod, err := kubecli.CoreV1().Pods(ns).Get(podName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if pod.Status.Phase == v1.PodFailed {
+			return false, fmt.Errorf("pod (%s) failed: %s", podName, pod.Status.Reason)
+		}
+		for _, cs := range pod.Status.InitContainerStatuses {
+			if cs.Name != k8sutil.RestoreContainer {
+				continue
+			}
+			if cs.State.Terminated != nil {
+				return false, fmt.Errorf("restore container of pod (%s) terminated before the restore", podName)
+			}
+			return cs.State.Running != nil, nil
+		}
+		return false, nil
+	})
+	if retryutil.IsRetryFailure(err) {
+		return fmt.Errorf("restore container of pod (%s) did not start in %v", podName, restoreContainerTimeout)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"testing"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+)
+
+func TestLatestRestorePolicy(t *testing.T) {
+	b := &api.ZookeeperBackup{
+		Spec: api.BackupSpec{
+			ClusterName: "zk",
+			StorageType: api.BackupStorageTypeS3,
+			BackupSource: api.BackupSource{
+				S3: &api.S3BackupSource{Path: "bucket/zk", AWSSecret: "aws", Endpoint: "http://minio:9000"},
+			},
+		},
+	}
+	if _, err := LatestRestorePolicy(b); err == nil {
+		t.Error("expected an error without successful backup")
+	}
+
+	b.Status.Path = "bucket/zk/zk_20180301T100000Z_10.tar.gz"
+	rp, err := LatestRestorePolicy(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.StorageType != api.BackupStorageTypeS3 || rp.S3.Path != b.Status.Path || rp.S3.Endpoint != "http://minio:9000" {
+		t.Errorf("unexpected restore policy: %+v", rp.S3)
+	}
+	if b.Spec.S3.Path != "bucket/zk" {
+		t.Errorf("the backup spec must not change, got path %s", b.Spec.S3.Path)
+	}
+}
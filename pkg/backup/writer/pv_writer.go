@@ -0,0 +1,93 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+type pvWriter struct {
+	restcfg   *rest.Config
+	kubecli   kubernetes.Interface
+	namespace string
+	podName   string
+}
+
+// NewPVWriter returns a writer storing the backups on the persistent volume mounted
+// by the given backup store pod. The paths of the writer are relative to the volume.
+func NewPVWriter(restcfg *rest.Config, kubecli kubernetes.Interface, ns, podName string) Writer {
+	return &pvWriter{
+		restcfg:   restcfg,
+		kubecli:   kubecli,
+		namespace: ns,
+		podName:   podName,
+	}
+}
+
+func (w *pvWriter) Write(p string, r io.Reader) (int64, error) {
+	// Write to a temporary file first so that a failed backup is never listed.
+	script := `mkdir -p "$(dirname "$1")" && cat > "$1.tmp" && mv "$1.tmp" "$1"`
+	fp, err := k8sutil.BackupStorePath(p)
+	if err != nil {
+		return 0, err
+	}
+	cr := &countingReader{r: r}
+	if err := w.exec(cr, ioutil.Discard, script, fp); err != nil {
+		return 0, err
+	}
+	return cr.n, nil
+}
+
+func (w *pvWriter) List(basePath string) ([]string, error) {
+	fp, err := k8sutil.BackupStorePath(basePath)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	script := `[ ! -d "$1" ] || ls -1 "$1"`
+	if err := w.exec(nil, &out, script, fp); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range strings.Fields(out.String()) {
+		if strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		paths = append(paths, path.Join(basePath, name))
+	}
+	return paths, nil
+}
+
+func (w *pvWriter) Delete(p string) error {
+	fp, err := k8sutil.BackupStorePath(p)
+	if err != nil {
+		return err
+	}
+	return w.exec(nil, ioutil.Discard, `rm -f "$1"`, fp)
+}
+
+func (w *pvWriter) exec(stdin io.Reader, stdout io.Writer, script, arg string) error {
+	cmd := []string{"sh", "-c", script, "sh", arg}
+	return k8sutil.ExecInContainer(w.restcfg, w.kubecli, w.namespace, w.podName, k8sutil.BackupStoreContainer, cmd, stdin, stdout)
+}
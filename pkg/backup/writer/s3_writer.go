@@ -0,0 +1,108 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+type s3Writer struct {
+	s3 *s3.S3
+}
+
+// NewS3Writer returns a writer storing the backups in an S3 compatible object store.
+// The paths of the writer are in the format "<bucket>/<key>".
+func NewS3Writer(sess *session.Session) Writer {
+	return &s3Writer{s3: s3.New(sess)}
+}
+
+func (w *s3Writer) Write(path string, r io.Reader) (int64, error) {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return 0, err
+	}
+	cr := &countingReader{r: r}
+	_, err = s3manager.NewUploaderWithClient(w.s3).Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   cr,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload backup (%s): %v", path, err)
+	}
+	return cr.n, nil
+}
+
+func (w *s3Writer) List(basePath string) ([]string, error) {
+	bucket, prefix, err := splitS3Path(basePath)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	err = w.s3.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(listPrefix(prefix)),
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			paths = append(paths, bucket+"/"+aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups (%s): %v", basePath, err)
+	}
+	return paths, nil
+}
+
+func (w *s3Writer) Delete(path string) error {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+	_, err = w.s3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete backup (%s): %v", path, err)
+	}
+	return nil
+}
+
+// listPrefix returns the prefix of the keys of the objects right under the given prefix.
+// Without the trailing slash, the objects of a sibling prefix would be listed too, e.g.
+// the ones under "backups2/" for "backups".
+func listPrefix(prefix string) string {
+	if len(prefix) == 0 || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+func splitS3Path(path string) (string, string, error) {
+	i := strings.Index(path, "/")
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid S3 path (%s): must be in the format <bucket>/<key>", path)
+	}
+	return path[:i], path[i+1:], nil
+}
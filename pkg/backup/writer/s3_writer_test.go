@@ -0,0 +1,34 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import "testing"
+
+func TestListPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: ""},
+		{prefix: "backups", want: "backups/"},
+		{prefix: "backups/", want: "backups/"},
+		{prefix: "team/backups", want: "team/backups/"},
+	}
+	for _, tt := range tests {
+		if get := listPrefix(tt.prefix); get != tt.want {
+			t.Errorf("%q: prefix get=%q, want=%q", tt.prefix, get, tt.want)
+		}
+	}
+}
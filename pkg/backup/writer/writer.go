@@ -0,0 +1,41 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import "io"
+
+// Writer stores backups in a backup storage.
+type Writer interface {
+	// Write writes the backup read from r to the given path and returns its size.
+	Write(path string, r io.Reader) (int64, error)
+
+	// List returns the paths of the backups stored under the given base path.
+	List(basePath string) ([]string, error)
+
+	// Delete deletes the backup at the given path.
+	Delete(path string) error
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// syncSASLSecret renders the JAAS file of the members from the SASL secrets of the
+// cluster and returns the secret holding it, or nil if SASL is disabled.
+func (c *Cluster) syncSASLSecret() (*v1.Secret, error) {
+	ap := c.cluster.Spec.Auth
+	if !ap.IsSASLEnabled() {
+		c.saslSuperPassword = ""
+		return nil, nil
+	}
+
+	var users k8sutil.SASLUsers
+	super, err := c.getSecret(ap.SASL.SuperUserSecret)
+	if err != nil {
+		return nil, err
+	}
+	users.SuperPassword = string(super.Data[k8sutil.SASLPasswordKey])
+	if len(users.SuperPassword) == 0 {
+		return nil, fmt.Errorf("secret (%s) has no %s", ap.SASL.SuperUserSecret, k8sutil.SASLPasswordKey)
+	}
+	if len(ap.SASL.ClientUsersSecret) != 0 {
+		clients, err := c.getSecret(ap.SASL.ClientUsersSecret)
+		if err != nil {
+			return nil, err
+		}
+		users.Clients = make(map[string]string)
+		for name, password := range clients.Data {
+			users.Clients[name] = string(password)
+		}
+	}
+	if ap.IsQuorumSASLEnabled() {
+		quorum, err := c.getSecret(ap.SASL.QuorumSecret)
+		if err != nil {
+			return nil, err
+		}
+		users.QuorumUser = string(quorum.Data[k8sutil.SASLUsernameKey])
+		users.QuorumPassword = string(quorum.Data[k8sutil.SASLPasswordKey])
+		if len(users.QuorumUser) == 0 || len(users.QuorumPassword) == 0 {
+			return nil, fmt.Errorf("secret (%s) has no %s or %s", ap.SASL.QuorumSecret, k8sutil.SASLUsernameKey, k8sutil.SASLPasswordKey)
+		}
+	}
+
+	secret := k8sutil.NewSASLSecret(c.cluster.Name, c.cluster.Namespace, users, c.cluster.AsOwner())
+	secret, err = k8sutil.CreateOrUpdateSecret(c.config.KubeCli, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render SASL secret: %v", err)
+	}
+	c.saslSuperPassword = users.SuperPassword
+	return secret, nil
+}
+
+// advanceQuorumSASLPhase moves the members to the next step of requiring quorum SASL
+// authentication, or of lifting the requirement. The new phase is rolled out by
+// restarting the members one at a time.
+func (c *Cluster) advanceQuorumSASLPhase() {
+	phase := c.status.QuorumSASLPhase.Toward(c.cluster.Spec.Auth.QuorumSASLTarget())
+	c.logger.Infof("changing the quorum SASL requirement: phase %q to %q", c.status.QuorumSASLPhase, phase)
+	c.status.QuorumSASLPhase = phase
+	_, err := c.eventsCli.Create(k8sutil.QuorumSASLPhaseEvent(phase, c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create quorum SASL phase event: %v", err)
+	}
+}
+
+// superUserPassword returns the password the operator authenticates as the super user
+// with, or an empty string if SASL is disabled.
+func (c *Cluster) superUserPassword() (string, error) {
+	ap := c.cluster.Spec.Auth
+	if !ap.IsSASLEnabled() {
+		return "", nil
+	}
+	if len(c.saslSuperPassword) != 0 {
+		return c.saslSuperPassword, nil
+	}
+	super, err := c.getSecret(ap.SASL.SuperUserSecret)
+	if err != nil {
+		return "", err
+	}
+	c.saslSuperPassword = string(super.Data[k8sutil.SASLPasswordKey])
+	return c.saslSuperPassword, nil
+}
+
+func (c *Cluster) getSecret(name string) (*v1.Secret, error) {
+	secret, err := c.config.KubeCli.CoreV1().Secrets(c.cluster.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret (%s): %v", name, err)
+	}
+	return secret, nil
+}
@@ -15,7 +15,9 @@
 package cluster
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -24,6 +26,7 @@ import (
 	"time"
 
 	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/backup"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/generated/clientset/versioned"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
@@ -35,6 +38,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 )
 
 var (
@@ -46,17 +50,21 @@ type clusterEventType string
 
 const (
 	eventModifyCluster clusterEventType = "Modify"
+	eventRejectCluster clusterEventType = "Reject"
 )
 
 type clusterEvent struct {
 	typ     clusterEventType
 	cluster *api.ZookeeperCluster
+	// err is the reason a modification was rejected.
+	err error
 }
 
 type Config struct {
 	ServiceAccount string
 
 	KubeCli   kubernetes.Interface
+	RestConfig *rest.Config
 	ZookeeperCRCli versioned.Interface
 }
 
@@ -79,6 +87,35 @@ type Cluster struct {
 	// process runs in.
 	members zookeeperutil.MemberSet
 
+	// rollout is the member that was last upgraded or restarted. The next member is
+	// only rolled once it has come back.
+	rollout *memberRollout
+	// rejectedVersion is the last version the upgrade to was rejected.
+	rejectedVersion string
+	// stoppedRollout is the rollout target the rolling restart was stopped at, if any.
+	stoppedRollout string
+	// rejected is the latest modification of the cluster if it has an invalid spec.
+	// The cluster keeps running with the previous spec until it is fixed.
+	rejected *api.ZookeeperCluster
+	// tlsConfig is the client TLS config of the operator, loaded from the operator secret.
+	tlsConfig *tls.Config
+	// memberSecretsHash is the hash of the TLS and SASL secrets new members are created with.
+	memberSecretsHash string
+	// saslSuperPassword is the password the operator authenticates as the super user with.
+	saslSuperPassword string
+	// certExpiryWarnings is when the certificate of each TLS secret was last warned about.
+	certExpiryWarnings map[string]time.Time
+	// quorumLostSince is when the majority of the members was found down, zero while the
+	// cluster has its quorum.
+	quorumLostSince time.Time
+	// lastLostMemberRestart is when a lost member was last restarted to recover the quorum.
+	lastLostMemberRestart time.Time
+	// lastMigration is when a member was last moved off an unhealthy node.
+	lastMigration time.Time
+	// restoreDone receives the outcome of the restore of the seed member running in the
+	// background, nil when no restore is running.
+	restoreDone chan error
+
 	eventsCli corev1.EventInterface
 }
 
@@ -93,6 +130,8 @@ func New(config Config, cl *api.ZookeeperCluster) *Cluster {
 		stopCh:    make(chan struct{}),
 		status:    *(cl.Status.DeepCopy()),
 		eventsCli: config.KubeCli.Core().Events(cl.Namespace),
+
+		certExpiryWarnings: make(map[string]time.Time),
 	}
 
 	go func() {
@@ -113,18 +152,22 @@ func New(config Config, cl *api.ZookeeperCluster) *Cluster {
 	return c
 }
 
+// ResolvePodServiceAddress returns the address the operator reaches the member on. The pod
+// IP of a member addressed by pod IP is looked up if it is not known yet. Out of the
+// cluster, e.g. during development, the operator always reaches the members by pod IP.
 func (c *Cluster) ResolvePodServiceAddress(member *zookeeperutil.Member) (string, error) {
-	contactPoint := ""
-	if len(os.Getenv("KUBECONFIG")) > 0 {
-		pod, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Get(member.Name, metav1.GetOptions{})
-		if err != nil {
-			return "", err
-		}
-		contactPoint = pod.Status.PodIP
-	} else {
-		contactPoint = member.Addr()
+	outOfCluster := len(os.Getenv("KUBECONFIG")) > 0
+	if !outOfCluster && (member.AddressType != zookeeperutil.AddressPodIP || len(member.PodIP) != 0) {
+		return operatorAddressed(member).Addr(), nil
 	}
-	return contactPoint, nil
+	pod, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Get(member.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if len(pod.Status.PodIP) == 0 {
+		return "", fmt.Errorf("member (%s) has no pod IP yet", member.Name)
+	}
+	return pod.Status.PodIP, nil
 }
 
 func (c *Cluster) setup() error {
@@ -149,12 +192,20 @@ func (c *Cluster) setup() error {
 
 func (c *Cluster) create() error {
 	c.status.SetPhase(api.ClusterPhaseCreating)
+	// A new cluster has no plaintext members to migrate.
+	if c.cluster.Spec.TLS.IsSecurePeer() {
+		c.status.QuorumTLSPhase = api.QuorumTLSPhaseSSLQuorum
+	}
+	c.status.QuorumSASLPhase = c.cluster.Spec.Auth.QuorumSASLTarget()
 
 	if err := c.updateCRStatus(); err != nil {
 		return fmt.Errorf("cluster create: failed to update cluster phase (%v): %v", api.ClusterPhaseCreating, err)
 	}
 	c.logClusterCreation()
 
+	if err := c.pollMemberSecrets(); err != nil {
+		c.logger.Warningf("failed to check member secrets: %v", err)
+	}
 	return c.prepareSeedMember()
 }
 
@@ -191,7 +242,7 @@ func (c *Cluster) run() {
 		c.logger.Errorf("fail to setup zookeeper services: %v", err)
 	}
 	c.status.ServiceName = k8sutil.ClientServiceName(c.cluster.Name)
-	c.status.ClientPort = k8sutil.ZookeeperClientPort
+	c.status.ClientPort = k8sutil.ClientServicePort(c.cluster.Spec)
 
 	c.status.SetPhase(api.ClusterPhaseRunning)
 	if err := c.updateCRStatus(); err != nil {
@@ -199,6 +250,16 @@ func (c *Cluster) run() {
 	}
 	c.logger.Infof("start running...")
 
+	if c.status.Restore.IsRunning() && c.restoreDone == nil {
+		if err := c.resumeRestore(); err != nil {
+			if err := c.finishRestore(err); err != nil {
+				c.status.SetReason(err.Error())
+				c.reportFailedStatus()
+				return
+			}
+		}
+	}
+
 	var rerr error
 	for {
 		select {
@@ -214,10 +275,22 @@ func (c *Cluster) run() {
 					c.reportFailedStatus()
 					return
 				}
+			case eventRejectCluster:
+				c.handleRejectEvent(event)
 			default:
 				panic("unknown event type" + event.typ)
 			}
 
+		case err := <-c.restoreDone:
+			if err := c.finishRestore(err); err != nil {
+				c.status.SetReason(err.Error())
+				c.reportFailedStatus()
+				return
+			}
+			if err := c.updateCRStatus(); err != nil {
+				c.logger.Warningf("update CR status failed: %v", err)
+			}
+
 		case <-time.After(reconcileInterval):
 			start := time.Now()
 
@@ -229,6 +302,11 @@ func (c *Cluster) run() {
 				c.status.Control()
 			}
 
+			if c.restoreDone != nil {
+				c.logger.Infof("skip reconciliation: restoring seed member (%s)", c.status.Restore.Member)
+				continue
+			}
+
 			running, pending, err := c.pollPods()
 			if err != nil {
 				c.logger.Errorf("fail to poll pods: %v", err)
@@ -242,15 +320,30 @@ func (c *Cluster) run() {
 				reconcileFailed.WithLabelValues("not all pods are running").Inc()
 				continue
 			}
-			if len(running) == 0 {
-				// TODO: how to handle this case?
-				c.logger.Warningf("all zookeeper pods are dead.")
+			// A cluster without quorum cannot answer, so it is recovered first.
+			if len(running) == 0 || (c.members != nil && lostQuorum(c.members, running)) {
+				if len(running) == 0 {
+					c.logger.Warningf("all zookeeper pods are dead.")
+				}
+				rerr = c.recoverQuorum(running)
+				if rerr != nil {
+					c.logger.Errorf("failed to recover quorum: %v", rerr)
+				}
+				if err := c.updateCRStatus(); err != nil {
+					c.logger.Warningf("update CR status failed: %v", err)
+				}
 				break
 			}
+			c.quorumRecovered()
+			c.updateMemberPodIPs(running)
+
+			if err := c.pollMemberSecrets(); err != nil {
+				c.logger.Warningf("failed to check member secrets: %v", err)
+			}
 
 			// On controller restore, we could have "members == nil"
 			if rerr != nil || c.members == nil {
-				rerr = c.updateMembers(podsToMemberSet(running))
+				rerr = c.updateMembers(c.podsToMemberSet(running))
 				if rerr != nil {
 					c.logger.Errorf("failed to update members: %v", rerr)
 					break
@@ -262,6 +355,15 @@ func (c *Cluster) run() {
 				break
 			}
 			c.updateMemberStatus(running)
+			if err := c.updateTopologyStatus(running); err != nil {
+				c.logger.Warningf("failed to update topology status: %v", err)
+			}
+			if err := c.reconcilePDB(); err != nil {
+				c.logger.Warningf("failed to reconcile pod disruption budget: %v", err)
+			}
+			if err := c.reconcileExternalAccess(running); err != nil {
+				c.logger.Warningf("failed to reconcile external access: %v", err)
+			}
 			if err := c.updateCRStatus(); err != nil {
 				c.logger.Warningf("periodic update CR status failed: %v", err)
 			}
@@ -283,8 +385,52 @@ func (c *Cluster) run() {
 }
 
 func (c *Cluster) handleUpdateEvent(event *clusterEvent) error {
+	if c.status.QuorumTLSPhase != api.QuorumTLSPhaseNone && !event.cluster.Spec.TLS.IsSecurePeer() {
+		c.handleRejectEvent(&clusterEvent{
+			typ:     eventRejectCluster,
+			cluster: event.cluster,
+			err:     errors.New("spec: tls static member peerSecret cannot be removed once the quorum uses TLS"),
+		})
+		return nil
+	}
+	if c.status.QuorumSASLPhase != api.QuorumSASLPhaseNone && !event.cluster.Spec.Auth.IsQuorumSASLEnabled() {
+		c.handleRejectEvent(&clusterEvent{
+			typ:     eventRejectCluster,
+			cluster: event.cluster,
+			err:     errors.New("spec: auth sasl quorumSecret cannot be removed while the members require quorum SASL"),
+		})
+		return nil
+	}
+	if event.cluster.Spec.TLS.IsPlainClientPortDisabled() && !c.cluster.Spec.TLS.IsPlainClientPortDisabled() {
+		if err := c.checkSecureClientServed(); err != nil {
+			c.handleRejectEvent(&clusterEvent{
+				typ:     eventRejectCluster,
+				cluster: event.cluster,
+				err:     fmt.Errorf("spec: tls static disablePlainClientPort can only be set once the members serve the secure client port: %v", err),
+			})
+			return nil
+		}
+	}
+	if !reflect.DeepEqual(event.cluster.Spec.MemberAddress, c.cluster.Spec.MemberAddress) {
+		c.handleRejectEvent(&clusterEvent{
+			typ:     eventRejectCluster,
+			cluster: event.cluster,
+			err:     errors.New("spec: memberAddress cannot be changed once the cluster is created"),
+		})
+		return nil
+	}
+	if sp, old := event.cluster.Spec.Service, c.cluster.Spec.Service; sp.MemberPeerPort() != old.MemberPeerPort() || sp.MemberLeaderPort() != old.MemberLeaderPort() {
+		c.handleRejectEvent(&clusterEvent{
+			typ:     eventRejectCluster,
+			cluster: event.cluster,
+			err:     errors.New("spec: service peerPort and leaderPort cannot be changed once the cluster is created"),
+		})
+		return nil
+	}
 	oldSpec := c.cluster.Spec.DeepCopy()
 	c.cluster = event.cluster
+	c.rejected = nil
+	c.status.ClearCondition(api.ClusterConditionSpecRejected)
 
 	if isSpecEqual(event.cluster.Spec, *oldSpec) {
 		// We have some fields that once created could not be mutated.
@@ -296,29 +442,69 @@ func (c *Cluster) handleUpdateEvent(event *clusterEvent) error {
 	// TODO: we can't handle another upgrade while an upgrade is in progress
 
 	c.logSpecUpdate(*oldSpec, event.cluster.Spec)
+
+	if !reflect.DeepEqual(event.cluster.Spec.TLS, oldSpec.TLS) {
+		c.tlsConfig = nil
+	}
+	if !reflect.DeepEqual(event.cluster.Spec.TLS, oldSpec.TLS) || !reflect.DeepEqual(event.cluster.Spec.Service, oldSpec.Service) {
+		c.status.ClientPort = k8sutil.ClientServicePort(c.cluster.Spec)
+		if err := c.setupServices(); err != nil {
+			return fmt.Errorf("fail to update zookeeper services: %v", err)
+		}
+	}
 	return nil
 }
 
+func (c *Cluster) handleRejectEvent(event *clusterEvent) {
+	c.rejected = event.cluster
+	if !c.status.SetSpecRejectedCondition(event.err.Error()) {
+		return
+	}
+	c.logger.Errorf("rejected spec update, keep running with the previous spec: %v", event.err)
+	_, err := c.eventsCli.Create(k8sutil.SpecRejectedEvent(event.err.Error(), c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create spec rejected event: %v", err)
+	}
+}
+
 func isSpecEqual(s1, s2 api.ClusterSpec) bool {
-	if s1.Size != s2.Size || s1.Paused != s2.Paused || s1.Version != s2.Version {
+	if s1.Size != s2.Size || s1.Paused != s2.Paused || s1.Version != s2.Version ||
+		s1.Image != s2.Image || s1.ImageTagFormat != s2.ImageTagFormat {
+		return false
+	}
+	if !reflect.DeepEqual(s1.Service, s2.Service) || !reflect.DeepEqual(s1.Observers, s2.Observers) ||
+		!reflect.DeepEqual(s1.TLS, s2.TLS) || !reflect.DeepEqual(s1.Auth, s2.Auth) {
 		return false
 	}
-	return true
+	h1, err1 := k8sutil.PodTemplateHash(s1, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone)
+	h2, err2 := k8sutil.PodTemplateHash(s2, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone)
+	return err1 == nil && err2 == nil && h1 == h2
 }
 
-func (c *Cluster) startSeedMember() error {
-	m := &zookeeperutil.Member{
-		Name:         fmt.Sprintf("%s-1", c.cluster.Name),
-		Namespace:    c.cluster.Namespace,
-	}
+// startSeedMember creates the first member of the cluster. If a restore policy is given
+// the member starts from its backup once it is restored in the background. rebuild tells
+// whether the backup is the one of the recovery policy.
+func (c *Cluster) startSeedMember(rp *api.RestorePolicy, rebuild bool) error {
+	m := c.newMemberNamed(fmt.Sprintf("%s-1", c.cluster.Name))
 	ms := zookeeperutil.NewMemberSet(m)
+	pod, err := c.newMemberPod(make([]string, 0), m, "seed")
+	if err != nil {
+		return fmt.Errorf("failed to create seed member (%s): %v", m.Name, err)
+	}
+	if rp != nil {
+		k8sutil.AddRestoreToPod(pod, c.cluster.Spec)
+	}
 	// TODO: @MDF: this fails if someone deletes/recreates a cluster too fast
-	if err := c.createPod(make([]string, 0), m, "seed"); err != nil {
+	if _, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Create(pod); err != nil {
 		return fmt.Errorf("failed to create seed member (%s): %v", m.Name, err)
 	}
+	if rp != nil {
+		c.status.Restore = &api.RestoreStatus{Member: m.Name, Path: rp.Path(), Rebuild: rebuild, Phase: api.RestorePhaseRunning}
+		c.restoreSeedMember(rp)
+	}
 	c.members = ms
 	c.logger.Infof("cluster created with seed member (%s)", m.Name)
-	_, err := c.eventsCli.Create(k8sutil.NewMemberAddEvent(m.Name, c.cluster))
+	_, err = c.eventsCli.Create(k8sutil.NewMemberAddEvent(m.Name, c.cluster))
 	if err != nil {
 		c.logger.Errorf("failed to create new member add event: %v", err)
 	}
@@ -326,9 +512,74 @@ func (c *Cluster) startSeedMember() error {
 	return nil
 }
 
+// restoreSeedMember extracts the backup of the restore policy into the seed member of the
+// restore status in the background. The seed member waits for it in its restore container,
+// so the cluster is not reconciled meanwhile. The outcome is handled by finishRestore.
+func (c *Cluster) restoreSeedMember(rp *api.RestorePolicy) {
+	member := c.status.Restore.Member
+	c.logger.Infof("restoring seed member (%s) from backup %s", member, rp.Path())
+	bc := backup.Config{
+		KubeCli:        c.config.KubeCli,
+		RestConfig:     c.config.RestConfig,
+		ZookeeperCRCli: c.config.ZookeeperCRCli,
+	}
+	cl := c.cluster.DeepCopy()
+	done := make(chan error, 1)
+	c.restoreDone = done
+	go func() {
+		done <- backup.RestoreMember(bc, cl, rp, member)
+	}()
+}
+
+// resumeRestore restarts the restore of the seed member that was running when the
+// operator stopped. The seed member is still waiting for it.
+func (c *Cluster) resumeRestore() error {
+	rp := c.cluster.Spec.Restore
+	if c.status.Restore.Rebuild {
+		var err error
+		if rp, err = c.rebuildRestorePolicy(); err != nil {
+			return err
+		}
+	}
+	if rp == nil {
+		return errors.New("the restore policy was removed")
+	}
+	c.restoreSeedMember(rp)
+	return nil
+}
+
+// finishRestore records the outcome of the restore of the seed member. A seed member
+// that could not be restored is removed, so that it does not wait in its restore
+// container forever. A new cluster then fails, while a cluster rebuilt from a backup
+// starts over on the next reconciliation.
+func (c *Cluster) finishRestore(err error) error {
+	c.restoreDone = nil
+	rs := c.status.Restore
+	if err == nil {
+		rs.Phase = api.RestorePhaseSucceeded
+		c.logger.Infof("seed member (%s) restored from backup %s", rs.Member, rs.Path)
+		_, err := c.eventsCli.Create(k8sutil.MemberRestoredEvent(rs.Member, c.cluster))
+		if err != nil {
+			c.logger.Errorf("failed to create member restored event: %v", err)
+		}
+		return nil
+	}
+
+	rs.Phase, rs.Reason = api.RestorePhaseFailed, err.Error()
+	c.logger.Errorf("failed to restore seed member (%s): %v", rs.Member, err)
+	if err := c.removePod(rs.Member, false); err != nil {
+		c.logger.Warningf("failed to remove the seed member: %v", err)
+	}
+	c.members = zookeeperutil.MemberSet{}
+	if rs.Rebuild {
+		return nil
+	}
+	return fmt.Errorf("failed to restore seed member (%s): %v", rs.Member, err)
+}
+
 // bootstrap creates the seed zookeeper member for a new cluster.
 func (c *Cluster) bootstrap() error {
-	return c.startSeedMember()
+	return c.startSeedMember(c.cluster.Spec.Restore, false)
 }
 
 func (c *Cluster) Update(cl *api.ZookeeperCluster) {
@@ -338,41 +589,131 @@ func (c *Cluster) Update(cl *api.ZookeeperCluster) {
 	})
 }
 
+// Reject tells the cluster that its latest modification has an invalid spec.
+func (c *Cluster) Reject(cl *api.ZookeeperCluster, err error) {
+	c.send(&clusterEvent{
+		typ:     eventRejectCluster,
+		cluster: cl,
+		err:     err,
+	})
+}
+
 func (c *Cluster) setupServices() error {
-	err := k8sutil.CreateClientService(c.config.KubeCli, c.cluster.Name, c.cluster.Namespace, c.cluster.AsOwner())
+	err := k8sutil.ReconcileClientService(c.config.KubeCli, c.cluster.Name, c.cluster.Namespace, c.cluster.Spec, c.cluster.AsOwner())
 	if err != nil {
 		return err
 	}
 
-	return k8sutil.CreatePeerService(c.config.KubeCli, c.cluster.Name, c.cluster.Namespace, c.cluster.AsOwner())
+	return k8sutil.ReconcilePeerService(c.config.KubeCli, c.cluster.Name, c.cluster.Namespace, c.cluster.Spec.Service, c.cluster.AsOwner())
+}
+
+// reconcilePDB keeps the pod disruption budget in line with the size of the ensemble, so
+// that voluntary disruptions such as node drains never take down its majority.
+func (c *Cluster) reconcilePDB() error {
+	return k8sutil.ReconcilePDB(c.config.KubeCli, c.cluster.Name, c.cluster.Namespace, c.members.Participants().Size(), c.cluster.AsOwner())
+}
+
+// memberPodPolicy returns the pod policy the pod of the member is created from.
+func (c *Cluster) memberPodPolicy(m *zookeeperutil.Member) *api.PodPolicy {
+	return k8sutil.MemberSpec(c.cluster.Spec, m.Observer).Pod
 }
 
+// isPodPVEnabled tells whether the pods of participants or observers get a data PVC.
 func (c *Cluster) isPodPVEnabled() bool {
-	if podPolicy := c.cluster.Spec.Pod; podPolicy != nil {
+	return c.isRolePVEnabled(false) || c.isRolePVEnabled(true)
+}
+
+// isRolePVEnabled tells whether the pods of the participants, or of the observers, get
+// a data PVC.
+func (c *Cluster) isRolePVEnabled(observer bool) bool {
+	if podPolicy := k8sutil.MemberSpec(c.cluster.Spec, observer).Pod; podPolicy != nil {
 		return podPolicy.PersistentVolumeClaimSpec != nil
 	}
 	return false
 }
 
-func (c *Cluster) createPod(existingCluster []string, m *zookeeperutil.Member, state string) error {
-	pod := k8sutil.NewZookeeperPod(m, existingCluster, c.cluster.Name, state, c.cluster.Spec, c.cluster.AsOwner())
-	// TODO: @MDF: add PV support
-	/*
-	if c.isPodPVEnabled() {
-		pvc := k8sutil.NewZookeeperPodPVC(m, *c.cluster.Spec.Pod.PersistentVolumeClaimSpec, c.cluster.Name, c.cluster.Namespace, c.cluster.AsOwner())
-		_, err := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace).Create(pvc)
-		if err != nil {
-			return fmt.Errorf("failed to create PVC for member (%s): %v", m.Name, err)
+// isTlogPVEnabled tells whether the pods of participants or observers get a transaction
+// log PVC.
+func (c *Cluster) isTlogPVEnabled() bool {
+	for _, observer := range []bool{false, true} {
+		if podPolicy := k8sutil.MemberSpec(c.cluster.Spec, observer).Pod; podPolicy != nil && podPolicy.TlogPersistentVolumeClaimSpec != nil {
+			return true
 		}
-		k8sutil.AddZookeeperVolumeToPod(pod, pvc)
-	} else {
-		k8sutil.AddZookeeperVolumeToPod(pod, nil)
 	}
-	*/
-	_, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Create(pod)
+	return false
+}
+
+func (c *Cluster) createPod(existingCluster []string, m *zookeeperutil.Member, state string) error {
+	pod, err := c.newMemberPod(existingCluster, m, state)
+	if err != nil {
+		return err
+	}
+	_, err = c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Create(pod)
 	return err
 }
 
+// newMemberPod returns the pod of the member, creating its PVCs if needed. New members
+// are placed in the least populated domain of the topology policy.
+func (c *Cluster) newMemberPod(existingCluster []string, m *zookeeperutil.Member, state string) (*v1.Pod, error) {
+	pod, err := k8sutil.NewZookeeperPod(m, existingCluster, c.cluster.Name, state, c.cluster.Spec, c.status.QuorumTLSPhase, c.status.QuorumSASLPhase, c.cluster.AsOwner())
+	if err != nil {
+		return nil, err
+	}
+	if len(c.memberSecretsHash) != 0 {
+		k8sutil.SetMemberSecretsHash(pod, c.memberSecretsHash)
+	}
+	if c.cluster.Spec.Topology != nil {
+		if err := c.steerToLeastPopulatedDomain(pod); err != nil {
+			return nil, err
+		}
+	}
+	var dataPVC, tlogPVC *v1.PersistentVolumeClaim
+	pp := c.memberPodPolicy(m)
+	if pp != nil && pp.PersistentVolumeClaimSpec != nil {
+		pvc := k8sutil.NewZookeeperPodPVC(m, *pp.PersistentVolumeClaimSpec, c.cluster.Name, c.cluster.Namespace, c.cluster.AsOwner())
+		var err error
+		if dataPVC, err = c.createPVC(pvc, m); err != nil {
+			return nil, err
+		}
+	}
+	if pp != nil && pp.TlogPersistentVolumeClaimSpec != nil {
+		pvc := k8sutil.NewZookeeperTlogPVC(m, *pp.TlogPersistentVolumeClaimSpec, c.cluster.Name, c.cluster.Namespace, c.cluster.AsOwner())
+		var err error
+		if tlogPVC, err = c.createPVC(pvc, m); err != nil {
+			return nil, err
+		}
+	}
+	k8sutil.AddZookeeperVolumeToPod(pod, dataPVC, tlogPVC)
+	return pod, nil
+}
+
+// createPVC creates the given PVC for the member. If the member had the PVC before,
+// e.g. it is being replaced after its pod died, the existing PVC is reused so the
+// member keeps its data.
+func (c *Cluster) createPVC(pvc *v1.PersistentVolumeClaim, m *zookeeperutil.Member) (*v1.PersistentVolumeClaim, error) {
+	ns := c.cluster.Namespace
+	_, err := c.config.KubeCli.CoreV1().PersistentVolumeClaims(ns).Create(pvc)
+	if err == nil {
+		return pvc, nil
+	}
+	if !k8sutil.IsKubernetesResourceAlreadyExistError(err) {
+		return nil, fmt.Errorf("failed to create PVC (%s) for member (%s): %v", pvc.Name, m.Name, err)
+	}
+
+	existing, err := c.config.KubeCli.CoreV1().PersistentVolumeClaims(ns).Get(pvc.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PVC (%s) for member (%s): %v", pvc.Name, m.Name, err)
+	}
+	if existing.DeletionTimestamp != nil {
+		return nil, fmt.Errorf("PVC (%s) of member (%s) is being deleted", existing.Name, m.Name)
+	}
+	if len(existing.OwnerReferences) < 1 || existing.OwnerReferences[0].UID != c.cluster.UID {
+		return nil, fmt.Errorf("PVC (%s) of member (%s) is not owned by this cluster", existing.Name, m.Name)
+	}
+	c.logger.Infof("reusing PVC (%s) for member (%s)", existing.Name, m.Name)
+	return existing, nil
+}
+
 func (c *Cluster) removePod(name string, wait bool) error {
 	ns := c.cluster.Namespace
 	gracePeriod := podTerminationGracePeriod
@@ -422,19 +763,29 @@ func (c *Cluster) pollPods() (running, pending []*v1.Pod, err error) {
 	return running, pending, nil
 }
 
+// updateMemberStatus reports the ready and unready participants, and the observers apart.
 func (c *Cluster) updateMemberStatus(running []*v1.Pod) {
 	var unready []string
 	var ready []string
+	var unreadyObservers []string
+	var readyObservers []string
 	for _, pod := range running {
-		if k8sutil.IsPodReady(pod) {
+		switch {
+		case k8sutil.IsObserverPod(pod) && k8sutil.IsPodReady(pod):
+			readyObservers = append(readyObservers, pod.Name)
+		case k8sutil.IsObserverPod(pod):
+			unreadyObservers = append(unreadyObservers, pod.Name)
+		case k8sutil.IsPodReady(pod):
 			ready = append(ready, pod.Name)
-			continue
+		default:
+			unready = append(unready, pod.Name)
 		}
-		unready = append(unready, pod.Name)
 	}
 
 	c.status.Members.Ready = ready
 	c.status.Members.Unready = unready
+	c.status.Members.ReadyObservers = readyObservers
+	c.status.Members.UnreadyObservers = unreadyObservers
 }
 
 func (c *Cluster) updateCRStatus() error {
@@ -442,6 +793,19 @@ func (c *Cluster) updateCRStatus() error {
 		return nil
 	}
 
+	if c.rejected != nil {
+		// Write the status without reverting the rejected spec of the user.
+		rejected := c.rejected.DeepCopy()
+		rejected.Status = c.status
+		rejected, err := c.config.ZookeeperCRCli.ZookeeperV1alpha1().ZookeeperClusters(c.cluster.Namespace).Update(rejected)
+		if err != nil {
+			return fmt.Errorf("failed to update CR status: %v", err)
+		}
+		c.rejected = rejected
+		c.cluster.Status = rejected.Status
+		return nil
+	}
+
 	newCluster := c.cluster
 	newCluster.Status = c.status
 	newCluster, err := c.config.ZookeeperCRCli.ZookeeperV1alpha1().ZookeeperClusters(c.cluster.Namespace).Update(c.cluster)
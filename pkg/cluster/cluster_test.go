@@ -15,10 +15,14 @@
 package cluster
 
 import (
+	"os"
 	"testing"
 
 	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
 
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -56,3 +60,125 @@ func TestUpdateEventUpdateLocalClusterObj(t *testing.T) {
 		t.Errorf("expect version=%s, get=%s", newVersion, c.cluster.ResourceVersion)
 	}
 }
+
+func TestResolvePodServiceAddress(t *testing.T) {
+	if len(os.Getenv("KUBECONFIG")) > 0 {
+		t.Skip("out of the cluster the operator reaches the members by pod IP")
+	}
+	c := &Cluster{cluster: &api.ZookeeperCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "zk"},
+		Spec:       api.ClusterSpec{MemberAddress: &api.MemberAddressPolicy{Type: api.MemberAddressShortName}},
+	}}
+	m := c.newMemberNamed("example-1")
+	if m.Addr() != "example-1.example" {
+		t.Fatalf("expected the members to address each other by short name, got %s", m.Addr())
+	}
+	addr, err := c.ResolvePodServiceAddress(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "example-1.example.zk.svc" {
+		t.Errorf("expected the operator to use the FQDN, got %s", addr)
+	}
+}
+
+// newPVCTestCluster returns a cluster whose members store their data on PVCs.
+func newPVCTestCluster() *Cluster {
+	c := newRolloutTestCluster()
+	c.cluster.UID = "uid-cluster"
+	c.cluster.Spec.Pod = &api.PodPolicy{PersistentVolumeClaimSpec: &v1.PersistentVolumeClaimSpec{
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+		},
+	}}
+	return c
+}
+
+// podClaims returns the claims mounted by the pod, by volume name.
+func podClaims(pod *v1.Pod) map[string]string {
+	claims := map[string]string{}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.PersistentVolumeClaim != nil {
+			claims[vol.Name] = vol.PersistentVolumeClaim.ClaimName
+		}
+	}
+	return claims
+}
+
+func TestNewMemberPodReusesPVC(t *testing.T) {
+	c := newPVCTestCluster()
+	pvcs := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace)
+	m := c.newMemberNamed("test-2")
+	pvcName := k8sutil.PVCNameFromMember(m.Name)
+
+	if _, err := c.newMemberPod(nil, m, "new"); err != nil {
+		t.Fatal(err)
+	}
+	pvc, err := pvcs.Get(pvcName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the PVC of the member to be created: %v", err)
+	}
+	// The claim got bound while the first pod ran.
+	pvc.Spec.VolumeName = "pv-test-2"
+	if _, err := pvcs.Update(pvc); err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err := c.newMemberPod(nil, m, "replacement")
+	if err != nil {
+		t.Fatalf("expected the replacement pod to reuse the PVC: %v", err)
+	}
+	if claim := podClaims(pod)["zookeeper-data"]; claim != pvcName {
+		t.Errorf("data volume claim get=%q, want=%q", claim, pvcName)
+	}
+	pvc, err = pvcs.Get(pvcName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pvc.Spec.VolumeName != "pv-test-2" {
+		t.Errorf("expected the bound PVC to be kept, got volume %q", pvc.Spec.VolumeName)
+	}
+}
+
+func TestNewMemberPodRejectsForeignPVC(t *testing.T) {
+	c := newPVCTestCluster()
+	m := c.newMemberNamed("test-2")
+	foreign := k8sutil.NewZookeeperPodPVC(m, *c.cluster.Spec.Pod.PersistentVolumeClaimSpec, "test", c.cluster.Namespace,
+		metav1.OwnerReference{UID: "uid-previous-cluster"})
+	if _, err := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace).Create(foreign); err != nil {
+		t.Fatal(err)
+	}
+	// The data of a deleted cluster of the same name must not be picked up.
+	if _, err := c.newMemberPod(nil, m, "new"); err == nil {
+		t.Errorf("expected the PVC of another cluster to be rejected")
+	}
+}
+
+func TestNewMemberPodTlogPVC(t *testing.T) {
+	c := newPVCTestCluster()
+	tlogSpec := *c.cluster.Spec.Pod.PersistentVolumeClaimSpec
+	fast := "fast"
+	tlogSpec.StorageClassName = &fast
+	c.cluster.Spec.Pod.TlogPersistentVolumeClaimSpec = &tlogSpec
+	m := c.newMemberNamed("test-1")
+
+	pod, err := c.newMemberPod(nil, m, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlogName := k8sutil.TlogPVCNameFromMember(m.Name)
+	pvc, err := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace).Get(tlogName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the transaction log PVC to be created: %v", err)
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != "fast" {
+		t.Errorf("expected the transaction log PVC to be created from its own template, got %v", pvc.Spec.StorageClassName)
+	}
+	if pvc.Labels["zookeeper_node"] != m.Name {
+		t.Errorf("expected the transaction log PVC to be labeled with its member, got %v", pvc.Labels)
+	}
+	claims := podClaims(pod)
+	if claims["zookeeper-data"] != k8sutil.PVCNameFromMember(m.Name) || claims["zookeeper-tlog"] != tlogName {
+		t.Errorf("unexpected claims of the pod: %v", claims)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileExternalAccess makes sure every member has an external service matching the
+// external access policy, removes the services of members that are gone and publishes
+// the external endpoints of the running members.
+func (c *Cluster) reconcileExternalAccess(running []*v1.Pod) error {
+	ns := c.cluster.Namespace
+	svcs, err := c.config.KubeCli.CoreV1().Services(ns).List(k8sutil.ExternalServiceListOpt(c.cluster.Name))
+	if err != nil {
+		return fmt.Errorf("failed to list external services: %v", err)
+	}
+	ea := c.cluster.Spec.ExternalAccess
+	existing := map[string]*v1.Service{}
+	for i := range svcs.Items {
+		svc := &svcs.Items[i]
+		existing[svc.Name] = svc
+	}
+
+	for name := range existing {
+		if ea != nil && c.members[memberOfExternalService(name)] != nil {
+			continue
+		}
+		c.logger.Infof("removing external service (%s)", name)
+		err := c.config.KubeCli.CoreV1().Services(ns).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !k8sutil.IsKubernetesResourceNotFoundError(err) {
+			return fmt.Errorf("failed to delete external service (%s): %v", name, err)
+		}
+		delete(existing, name)
+	}
+	if ea == nil {
+		c.status.ExternalEndpoints = nil
+		return nil
+	}
+
+	for name := range c.members {
+		want := k8sutil.NewExternalService(name, c.cluster.Name, ea, c.cluster.Spec.TLS, c.cluster.AsOwner())
+		svc, ok := existing[want.Name]
+		if !ok {
+			c.logger.Infof("creating external service (%s)", want.Name)
+			svc, err = c.config.KubeCli.CoreV1().Services(ns).Create(want)
+			if err != nil {
+				return fmt.Errorf("failed to create external service (%s): %v", want.Name, err)
+			}
+		} else if k8sutil.UpdateExternalService(svc, want) {
+			c.logger.Infof("updating external service (%s)", want.Name)
+			svc, err = c.config.KubeCli.CoreV1().Services(ns).Update(svc)
+			if err != nil {
+				return fmt.Errorf("failed to update external service (%s): %v", want.Name, err)
+			}
+		}
+		existing[want.Name] = svc
+	}
+
+	var endpoints []api.ExternalEndpoint
+	for _, pod := range running {
+		svc, ok := existing[k8sutil.ExternalServiceName(pod.Name)]
+		if !ok {
+			continue
+		}
+		var node *v1.Node
+		if svc.Spec.Type == v1.ServiceTypeNodePort && pod.Spec.NodeName != "" {
+			node, err = c.config.KubeCli.CoreV1().Nodes().Get(pod.Spec.NodeName, metav1.GetOptions{})
+			if err != nil {
+				c.logger.Warningf("failed to get node (%s) of member (%s): %v", pod.Spec.NodeName, pod.Name, err)
+				node = nil
+			}
+		}
+		endpoints = append(endpoints, k8sutil.ExternalEndpoint(pod.Name, svc, node))
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Member < endpoints[j].Member })
+	c.status.ExternalEndpoints = endpoints
+	return nil
+}
+
+// memberOfExternalService returns the name of the member an external service exposes.
+func memberOfExternalService(svcName string) string {
+	return strings.TrimSuffix(svcName, "-external")
+}
@@ -16,47 +16,170 @@ package cluster
 
 import (
 	"fmt"
-	"strings"
 
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
 
 	"k8s.io/api/core/v1"
 )
 
 func (c *Cluster) updateMembers(known zookeeperutil.MemberSet) error {
-	resp, err := zookeeperutil.GetClusterConfig(known.ClientHostList())
+	clientHosts, cc, err := c.clientHosts(known)
+	if err != nil {
+		return err
+	}
+	resp, err := zookeeperutil.GetClusterConfig(clientHosts, cc)
 	if err != nil {
 		return err
 	}
 
 	members := zookeeperutil.MemberSet{}
 	for _, serverConfig := range resp {
-		leaderClientSplit := strings.Split(serverConfig, ";")
-		clientHostname := strings.Split(leaderClientSplit[1], ":")[0]
-		clientName := strings.Split(clientHostname, ".")[0]
-		members[clientName] = &zookeeperutil.Member{
-			Name:         clientName,
-			Namespace:    c.cluster.Namespace,
+		// The client address may be localhost, so the name is taken from the quorum address.
+		clientName, err := zookeeperutil.MemberNameFromServerConfig(c.cluster.Name, serverConfig)
+		if err != nil {
+			return err
 		}
-
+		m := c.newMemberNamed(clientName)
+		m.Observer = zookeeperutil.IsObserverServerConfig(serverConfig)
+		if k, ok := known[clientName]; ok {
+			m.PodIP = k.PodIP
+		}
+		members[clientName] = m
 	}
 	c.members = members
 	return nil
 }
 
+// reconfigureMembers makes the given members the configuration of the ensemble.
+func (c *Cluster) reconfigureMembers(ms zookeeperutil.MemberSet) error {
+	clientHosts, cc, err := c.clientHosts(ms)
+	if err != nil {
+		return err
+	}
+	_, err = zookeeperutil.ReconfigureCluster(clientHosts, ms.ClusterConfig(c.cluster.Spec.TLS.IsPlainClientPortDisabled()), cc)
+	return err
+}
+
 func (c *Cluster) newMember() *zookeeperutil.Member {
 	name := fmt.Sprintf("%s-%d", c.cluster.Name, c.members.MaxMemberID()+1)
+	return c.newMemberNamed(name)
+}
+
+// newMemberNamed returns the member with the given name, addressed as the member address
+// policy of the cluster says, on the quorum and leader election ports of the service policy.
+func (c *Cluster) newMemberNamed(name string) *zookeeperutil.Member {
+	ap := c.cluster.Spec.MemberAddress
+	sp := c.cluster.Spec.Service
 	return &zookeeperutil.Member{
-		Name:         name,
-		Namespace:    c.cluster.Namespace,
+		Name:          name,
+		Namespace:     c.cluster.Namespace,
+		AddressType:   zookeeperutil.AddressType(ap.AddressType()),
+		ClusterDomain: ap.Domain(),
+		PeerPort:      sp.MemberPeerPort(),
+		LeaderPort:    sp.MemberLeaderPort(),
 	}
 }
 
-func podsToMemberSet(pods []*v1.Pod) zookeeperutil.MemberSet {
+func (c *Cluster) podsToMemberSet(pods []*v1.Pod) zookeeperutil.MemberSet {
 	members := zookeeperutil.MemberSet{}
 	for _, pod := range pods {
-		m := &zookeeperutil.Member{Name: pod.Name, Namespace: pod.Namespace}
+		m := c.newMemberNamed(pod.Name)
+		m.PodIP = pod.Status.PodIP
+		m.Observer = k8sutil.IsObserverPod(pod)
 		members.Add(m)
 	}
 	return members
 }
+
+// participantPods returns the pods of the participants, leaving out the observers.
+func participantPods(pods []*v1.Pod) []*v1.Pod {
+	var res []*v1.Pod
+	for _, pod := range pods {
+		if !k8sutil.IsObserverPod(pod) {
+			res = append(res, pod)
+		}
+	}
+	return res
+}
+
+// operatorAddressed returns the member as the operator addresses it. A short name only
+// resolves in the namespace of the cluster, while the operator may run in another one,
+// so the operator uses the FQDN of the member instead.
+func operatorAddressed(m *zookeeperutil.Member) *zookeeperutil.Member {
+	if m.AddressType != zookeeperutil.AddressShortName {
+		return m
+	}
+	om := *m
+	om.AddressType = zookeeperutil.AddressFQDN
+	return &om
+}
+
+// updateMemberPodIPs records the pod IPs of the running members, which change when the
+// pods of members addressed by pod IP are recreated.
+func (c *Cluster) updateMemberPodIPs(running []*v1.Pod) {
+	for _, pod := range running {
+		if m, ok := c.members[pod.Name]; ok {
+			m.PodIP = pod.Status.PodIP
+		}
+	}
+}
+
+// isMemberServing tells whether the member serves requests as part of the quorum. Members
+// that do not answer to srvr are considered serving when they answer to ruok.
+func (c *Cluster) isMemberServing(m *zookeeperutil.Member) bool {
+	host, tlsConfig, err := c.memberClientHost(m)
+	if err != nil {
+		return false
+	}
+	stats, err := zookeeperutil.GetServerStats(host, tlsConfig)
+	if err == zookeeperutil.ErrNotWhitelisted {
+		return zookeeperutil.IsServerOK(host, tlsConfig)
+	}
+	if err != nil {
+		return false
+	}
+	return stats.Mode == zookeeperutil.ModeLeader || stats.Mode == zookeeperutil.ModeFollower
+}
+
+// checkQuorum returns an error unless a majority of the given members serves requests.
+// Observers do not vote and are not counted.
+func (c *Cluster) checkQuorum(ms zookeeperutil.MemberSet) error {
+	ms = ms.Participants()
+	serving := 0
+	for _, m := range ms {
+		if c.isMemberServing(m) {
+			serving++
+		}
+	}
+	if !isMajority(serving, ms.Size()) {
+		return fmt.Errorf("only %d of %d members serve requests", serving, ms.Size())
+	}
+	return nil
+}
+
+// checkQuorumWithout returns an error unless the other participants serving requests are
+// a majority of the ensemble, i.e. unless the ensemble keeps its quorum while the given
+// member is down. The member stays in the configuration, so the majority is taken of the
+// whole ensemble. Observers do not vote, taking one down never costs the quorum.
+func (c *Cluster) checkQuorumWithout(name string) error {
+	ms := c.members.Participants()
+	if _, ok := ms[name]; !ok {
+		return nil
+	}
+	serving := 0
+	for _, m := range ms {
+		if m.Name != name && c.isMemberServing(m) {
+			serving++
+		}
+	}
+	if !isMajority(serving, ms.Size()) {
+		return fmt.Errorf("only %d other of %d members serve requests, the ensemble would lose its quorum", serving, ms.Size())
+	}
+	return nil
+}
+
+// isMajority tells whether n members are a majority of an ensemble of the given size.
+func isMajority(n, size int) bool {
+	return n >= size/2+1
+}
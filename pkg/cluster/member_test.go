@@ -0,0 +1,38 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import "testing"
+
+func TestIsMajority(t *testing.T) {
+	tests := []struct {
+		n, size   int
+		wMajority bool
+	}{
+		{n: 1, size: 1, wMajority: true},
+		{n: 1, size: 2, wMajority: false},
+		{n: 2, size: 2, wMajority: true},
+		// Scaling 4 down to 3 needs 2 of the remaining 3 serving.
+		{n: 2, size: 3, wMajority: true},
+		{n: 1, size: 3, wMajority: false},
+		{n: 2, size: 4, wMajority: false},
+		{n: 3, size: 5, wMajority: true},
+	}
+	for _, tt := range tests {
+		if get := isMajority(tt.n, tt.size); get != tt.wMajority {
+			t.Errorf("%d of %d: majority get=%v, want=%v", tt.n, tt.size, get, tt.wMajority)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeUnhealthyReason returns why the members should be moved off the node, or an empty
+// string if they can stay. A node must have been not ready for notReadyTimeout.
+func nodeUnhealthyReason(n *v1.Node, notReadyTimeout time.Duration, now time.Time) string {
+	if n.Spec.Unschedulable {
+		return "node is cordoned"
+	}
+	if k8sutil.IsNodeReady(*n) {
+		return ""
+	}
+	for _, cd := range n.Status.Conditions {
+		if cd.Type == v1.NodeReady && now.Sub(cd.LastTransitionTime.Time) >= notReadyTimeout {
+			return fmt.Sprintf("node is not ready since %s", cd.LastTransitionTime.Format(time.RFC3339))
+		}
+	}
+	return ""
+}
+
+// migrateMember replaces a member running on a node that is not ready or cordoned, as
+// long as the other members are ready and the last migration is old enough. It returns
+// whether a member was replaced.
+func (c *Cluster) migrateMember(pods []*v1.Pod) (bool, error) {
+	mp := c.cluster.Spec.Migration
+	if !mp.IsEnabled() || c.rollout != nil {
+		return false, nil
+	}
+	sorted := append([]*v1.Pod{}, pods...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	nodes := map[string]*v1.Node{}
+	for _, pod := range sorted {
+		m, ok := c.members[pod.Name]
+		if !ok || len(pod.Spec.NodeName) == 0 {
+			continue
+		}
+		n, ok := nodes[pod.Spec.NodeName]
+		if !ok {
+			var err error
+			n, err = c.config.KubeCli.CoreV1().Nodes().Get(pod.Spec.NodeName, metav1.GetOptions{})
+			if err != nil {
+				if k8sutil.IsKubernetesResourceNotFoundError(err) {
+					continue
+				}
+				return false, fmt.Errorf("failed to get node (%s) of member (%s): %v", pod.Spec.NodeName, pod.Name, err)
+			}
+			nodes[n.Name] = n
+		}
+		reason := nodeUnhealthyReason(n, mp.NodeNotReadyTimeout(), time.Now())
+		if len(reason) == 0 {
+			continue
+		}
+
+		if wait := mp.MinInterval() - time.Since(c.lastMigration); wait > 0 {
+			c.logger.Infof("member (%s) is moved off node (%s) in %v: %s", m.Name, n.Name, wait, reason)
+			return false, nil
+		}
+		for _, other := range pods {
+			if other.Name != pod.Name && !k8sutil.IsPodReady(other) {
+				c.logger.Infof("member (%s) stays on node (%s) until member (%s) is ready", m.Name, n.Name, other.Name)
+				return false, nil
+			}
+		}
+		// The replaced member stays in the configuration, so the other members must be a
+		// majority of the whole ensemble.
+		if err := c.checkQuorumWithout(m.Name); err != nil {
+			c.logger.Infof("member (%s) stays on node (%s): %v", m.Name, n.Name, err)
+			return false, nil
+		}
+
+		c.logger.Infof("moving member (%s) off node (%s): %s", m.Name, n.Name, reason)
+		_, err := c.eventsCli.Create(k8sutil.MemberMigrationEvent(m.Name, n.Name, reason, c.cluster))
+		if err != nil {
+			c.logger.Errorf("failed to create member migration event: %v", err)
+		}
+		c.lastMigration = time.Now()
+		return true, c.replaceDeadMember(m)
+	}
+	return false, nil
+}
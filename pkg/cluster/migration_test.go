@@ -0,0 +1,93 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeUnhealthyReason(t *testing.T) {
+	now := time.Now()
+	node := func(unschedulable bool, ready v1.ConditionStatus, since time.Duration) *v1.Node {
+		return &v1.Node{
+			Spec: v1.NodeSpec{Unschedulable: unschedulable},
+			Status: v1.NodeStatus{Conditions: []v1.NodeCondition{{
+				Type:               v1.NodeReady,
+				Status:             ready,
+				LastTransitionTime: metav1.NewTime(now.Add(-since)),
+			}}},
+		}
+	}
+	tests := []struct {
+		node    *v1.Node
+		wReason bool
+	}{{
+		node:    node(false, v1.ConditionTrue, time.Hour),
+		wReason: false,
+	}, {
+		node:    node(true, v1.ConditionTrue, time.Hour),
+		wReason: true,
+	}, {
+		// Not ready for less than the timeout.
+		node:    node(false, v1.ConditionFalse, 10*time.Second),
+		wReason: false,
+	}, {
+		node:    node(false, v1.ConditionUnknown, 2*time.Minute),
+		wReason: true,
+	}}
+	for i, tt := range tests {
+		reason := nodeUnhealthyReason(tt.node, time.Minute, now)
+		if (len(reason) != 0) != tt.wReason {
+			t.Errorf("#%d: reason get=%q, want reason=%v", i, reason, tt.wReason)
+		}
+	}
+}
+
+func TestMigrateMemberKeepsQuorum(t *testing.T) {
+	c := newRolloutTestCluster()
+	c.cluster.Spec.Size = 1
+	c.cluster.Spec.Migration = &api.MigrationPolicy{Enabled: true}
+	c.members = zookeeperutil.NewMemberSet(c.newMemberNamed("test-1"))
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{Unschedulable: true}}
+	if _, err := c.config.KubeCli.CoreV1().Nodes().Create(node); err != nil {
+		t.Fatal(err)
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-1", Namespace: c.cluster.Namespace},
+		Spec:       v1.PodSpec{NodeName: node.Name},
+	}
+	if _, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Create(pod); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ensemble has no quorum without its only member.
+	migrated, err := c.migrateMember([]*v1.Pod{pod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated {
+		t.Errorf("expected the only member to stay on the cordoned node")
+	}
+	if _, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Get(pod.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the pod of the member to be kept: %v", err)
+	}
+}
@@ -37,22 +37,25 @@ func (c *Cluster) reconcile(pods []*v1.Pod) error {
 	defer c.logger.Infoln("Finish reconciling")
 
 	defer func() {
-		c.status.Size = c.members.Size()
+		c.status.Size = c.members.Participants().Size()
 	}()
 
 	sp := c.cluster.Spec
-	running := podsToMemberSet(pods)
+	running := c.podsToMemberSet(pods)
 	// Reconfigure required if running == membership but clusterConfig != membership
 	if running.IsEqual(c.members) {
-		clientHosts := c.members.ClientHostList()
-		zkClusterConfig, err := zookeeperutil.GetClusterConfig(clientHosts)
+		clientHosts, cc, err := c.clientHosts(c.members)
 		if err != nil {
 			return err
 		}
-		memberClusterConfig := c.members.ClusterConfig()
+		zkClusterConfig, err := zookeeperutil.GetClusterConfig(clientHosts, cc)
+		if err != nil {
+			return err
+		}
+		memberClusterConfig := c.members.ClusterConfig(sp.TLS.IsPlainClientPortDisabled())
 		if len(zkClusterConfig) != c.members.Size() || !reflect.DeepEqual(zkClusterConfig, memberClusterConfig) {
 			c.logger.Infoln("Reconfiguring ZK cluster")
-			config, err := zookeeperutil.ReconfigureCluster(clientHosts, memberClusterConfig)
+			config, err := zookeeperutil.ReconfigureCluster(clientHosts, memberClusterConfig, cc)
 			if err != nil {
 				c.logger.Infoln("Reconfigure error")
 				return err
@@ -62,20 +65,111 @@ func (c *Cluster) reconcile(pods []*v1.Pod) error {
 		}
 	}
 	// If not enough are running or membership size != spec size then maybe resize
-	if !running.IsEqual(c.members) || c.members.Size() != sp.Size {
+	if !running.IsEqual(c.members) || c.members.Participants().Size() != sp.Size || c.members.Observers().Size() != sp.Observers.Size() {
 		return c.reconcileMembers(running)
 	}
 	c.status.ClearCondition(api.ClusterConditionScaling)
 
-	// TODO: @MDF: Try and upgrade the leader last, that way we don't bounce it around repeatedly
-	if needUpgrade(pods, sp) {
-		c.status.UpgradeVersionTo(sp.Version)
+	if c.isPodPVEnabled() || c.isTlogPVEnabled() {
+		if err := c.removeOrphanPVCs(); err != nil {
+			c.logger.Warningf("failed to garbage collect PVCs: %v", err)
+		}
+	}
 
-		m := pickOneOldMember(pods, sp.Version)
-		return c.upgradeOneMember(m.Name)
+	// Members on nodes that are not ready or cordoned are moved one at a time, before
+	// the eviction of their pods takes several of them down at once.
+	if migrated, err := c.migrateMember(pods); migrated || err != nil {
+		return err
+	}
+
+	// Members are upgraded and restarted one at a time, followers first and the leader
+	// last, and only once the previous one has rejoined and synced with the leader.
+	// The upgrade lasts until the last upgraded member is back.
+	if needUpgrade(pods, sp) || c.status.TargetVersion == sp.Version {
+		if c.status.IsUpgradeFailed(sp.Version) {
+			c.logger.Warningf("upgrade to %s is stopped, waiting for the version to change", sp.Version)
+			return nil
+		}
+		if c.status.TargetVersion != sp.Version {
+			if err := checkUpgradePath(pods, sp.Version, upgradePath(sp.Upgrade)); err != nil {
+				c.rejectUpgrade(err)
+				return nil
+			}
+		}
+		c.status.UpgradeVersionTo(sp.Version)
+		if !c.isRolloutDone(pods) {
+			if msg := c.rolloutImagePullError(pods); len(msg) != 0 {
+				c.stopUpgrade("Member image pull failed", msg)
+				return nil
+			}
+			if c.isRolloutTimedOut() {
+				c.stopUpgrade("Member upgrade timed out", fmt.Sprintf("not ready and synced after %v", c.memberUpgradeTimeout()))
+				return nil
+			}
+			// A member that is down does not serve anyway, so rolling it cannot cost the
+			// quorum. This lets a rollback start with the member a failed upgrade broke.
+			if m := pickOneUnreadyMember(pods, oldMembers(pods, sp)); c.rollout == nil && m != nil {
+				return c.upgradeOneMember(m.Name)
+			}
+			c.logger.Infof("waiting for all members to rejoin and sync before upgrading the next one")
+			return nil
+		}
+		if needUpgrade(pods, sp) {
+			m, err := c.pickOneMemberLeaderLast(oldMembers(pods, sp))
+			if err != nil {
+				return err
+			}
+			return c.upgradeOneMember(m.Name)
+		}
 	}
 	c.status.ClearCondition(api.ClusterConditionUpgrading)
 
+	// Pod level changes require a new pod. A restart that times out stops the rollout
+	// until the pod template or the member secrets change.
+	hashes, err := c.podTemplateHashes()
+	if err != nil {
+		return err
+	}
+	if target := c.rolloutTarget(hashes); len(c.stoppedRollout) != 0 && c.stoppedRollout != target {
+		c.logger.Infof("members changed since the restart was stopped, resuming it")
+		c.stoppedRollout = ""
+		c.status.ClearCondition(api.ClusterConditionRestarting)
+	}
+	if needRollout(pods, sp, hashes) {
+		return c.restartNextMember(pods, staleMembers(pods, hashes), "pod spec changed", hashes)
+	}
+
+	// An image that changes without the version is not an upgrade, the members are
+	// restarted with it like for a pod spec change.
+	if ms := imageChangedMembers(pods, sp); len(ms) != 0 && len(pods) == clusterPods(sp) {
+		return c.restartNextMember(pods, ms, "image changed", hashes)
+	}
+
+	// Members only pick up changed TLS and SASL secrets when they are restarted.
+	if ms := c.changedSecretsMembers(pods); len(ms) != 0 && len(pods) == clusterPods(sp) {
+		return c.restartNextMember(pods, ms, "member secrets changed", hashes)
+	}
+
+	if sp.TLS.IsSecurePeer() && c.status.QuorumTLSPhase != api.QuorumTLSPhaseSSLQuorum {
+		if c.isRestartStopped() || c.waitForRollout(pods, "migrating the quorum to TLS", hashes) {
+			return nil
+		}
+		c.advanceQuorumTLSPhase()
+		return nil
+	}
+
+	if c.status.QuorumSASLPhase != sp.Auth.QuorumSASLTarget() {
+		if c.isRestartStopped() || c.waitForRollout(pods, "changing the quorum SASL requirement", hashes) {
+			return nil
+		}
+		c.advanceQuorumSASLPhase()
+		return nil
+	}
+
+	// The last restarted member may have come back after the restart was stopped.
+	if c.rollout == nil && len(c.stoppedRollout) == 0 {
+		c.status.ClearCondition(api.ClusterConditionRestarting)
+	}
 	c.status.SetVersion(sp.Version)
 	c.status.SetReadyCondition()
 
@@ -89,7 +183,7 @@ func (c *Cluster) reconcile(pods []*v1.Pod) error {
 // 1. Remove all pods from running set that does not belong to member set.
 // 2. L consist of remaining pods of runnings
 // 3. If L = members, the current state matches the membership state. END.
-// 4. If len(L) < len(members)/2 + 1, return quorum lost error.
+// 4. If len(L participants) < len(members participants)/2 + 1, return quorum lost error.
 // 5. Add one missing member. END.
 func (c *Cluster) reconcileMembers(running zookeeperutil.MemberSet) error {
 	c.logger.Infof("running members: %s", running)
@@ -110,7 +204,7 @@ func (c *Cluster) reconcileMembers(running zookeeperutil.MemberSet) error {
 		return c.resize()
 	}
 
-	if L.Size() < c.members.Size()/2+1 {
+	if L.Participants().Size() < c.members.Participants().Size()/2+1 {
 		return ErrLostQuorum
 	}
 
@@ -119,27 +213,45 @@ func (c *Cluster) reconcileMembers(running zookeeperutil.MemberSet) error {
 	return c.replaceDeadMember(c.members.Diff(L).PickOne())
 }
 
+// resize brings the participants to the size of the cluster first, then the observers
+// to their count.
 func (c *Cluster) resize() error {
-	if c.members.Size() == c.cluster.Spec.Size {
-		return nil
-	}
-
-	if c.members.Size() < c.cluster.Spec.Size {
+	participants := c.members.Participants().Size()
+	if participants < c.cluster.Spec.Size {
 		// TODO: @MDF: Perhaps we want to add 2x at a time if we currently have an odd membership, we should be able to do that
 		return c.addOneMember()
 	}
+	if participants > c.cluster.Spec.Size {
+		return c.removeOneMember()
+	}
 
-	return c.removeOneMember()
+	observers := c.members.Observers().Size()
+	if observers < c.cluster.Spec.Observers.Size() {
+		return c.addOneObserver()
+	}
+	if observers > c.cluster.Spec.Observers.Size() {
+		return c.removeOneObserver()
+	}
+	return nil
 }
 
 func (c *Cluster) addOneMember() error {
-	c.status.SetScalingUpCondition(c.members.Size(), c.cluster.Spec.Size)
+	c.status.SetScalingUpCondition(c.members.Participants().Size(), c.cluster.Spec.Size)
 	newMember := c.newMember()
 	return c.addMember(newMember, "new")
 }
 
+// addOneObserver adds an observer. It joins as an observer like a new participant, but
+// stays one when the reconfiguration adds it to the ensemble.
+func (c *Cluster) addOneObserver() error {
+	c.logger.Infof("scaling up observers from %d to %d", c.members.Observers().Size(), c.cluster.Spec.Observers.Size())
+	newMember := c.newMember()
+	newMember.Observer = true
+	return c.addMember(newMember, "new")
+}
+
 func (c *Cluster) addMember(toAdd *zookeeperutil.Member, state string) error {
-	existingCluster := c.members.ClusterConfig()
+	existingCluster := c.members.ClusterConfig(c.cluster.Spec.TLS.IsPlainClientPortDisabled())
 	c.members.Add(toAdd)
 
 	if err := c.createPod(existingCluster, toAdd, state); err != nil {
@@ -154,10 +266,33 @@ func (c *Cluster) addMember(toAdd *zookeeperutil.Member, state string) error {
 }
 
 func (c *Cluster) removeOneMember() error {
-	c.status.SetScalingDownCondition(c.members.Size(), c.cluster.Spec.Size)
+	c.status.SetScalingDownCondition(c.members.Participants().Size(), c.cluster.Spec.Size)
 
-	// TODO: @MDF: Be smarter, don't pick the leader
-	return c.removeMember(c.members.PickOne(), true)
+	var candidates []*zookeeperutil.Member
+	for _, m := range c.members.Participants() {
+		candidates = append(candidates, m)
+	}
+	toRemove, err := c.pickOneMemberLeaderLast(candidates)
+	if err != nil {
+		return fmt.Errorf("fail to pick the member to remove: %v", err)
+	}
+	if err := c.checkQuorum(c.members.Diff(zookeeperutil.NewMemberSet(toRemove))); err != nil {
+		return fmt.Errorf("fail to remove member (%s): %v", toRemove.Name, err)
+	}
+	return c.removeMember(toRemove, true)
+}
+
+// removeOneObserver removes the observer with the highest ID. Observers do not vote, so
+// removing one never costs the quorum.
+func (c *Cluster) removeOneObserver() error {
+	c.logger.Infof("scaling down observers from %d to %d", c.members.Observers().Size(), c.cluster.Spec.Observers.Size())
+	var toRemove *zookeeperutil.Member
+	for _, m := range c.members.Observers() {
+		if toRemove == nil || m.ID() > toRemove.ID() {
+			toRemove = m
+		}
+	}
+	return c.removeMember(toRemove, true)
 }
 
 func (c *Cluster) replaceDeadMember(toReplace *zookeeperutil.Member) error {
@@ -186,10 +321,12 @@ func (c *Cluster) removeMember(toRemove *zookeeperutil.Member, isScalingEvent bo
 	c.members.Remove(toRemove.Name)
 
 	if isScalingEvent {
-		// Perform a cluster reconfigure dropping the node to be removed
-		_, err = zookeeperutil.ReconfigureCluster(c.members.ClientHostList(), c.members.ClusterConfig())
+		// Perform a cluster reconfigure dropping the node to be removed. The pod is only
+		// deleted once the remaining members have accepted the new configuration.
+		err = c.reconfigureMembers(c.members)
 		if err != nil {
-			c.logger.Errorf("failed to reconfigure remove member from cluster: %v", err)
+			c.members.Add(toRemove)
+			return fmt.Errorf("fail to reconfigure the cluster: %v", err)
 		}
 	}
 
@@ -201,16 +338,21 @@ func (c *Cluster) removeMember(toRemove *zookeeperutil.Member, isScalingEvent bo
 	if err := c.removePod(toRemove.Name, isScalingEvent); err != nil {
 		return err
 	}
-	// TODO: @MDF: Add PV support
-	/*
-	if c.isPodPVEnabled() {
+	// A replaced member keeps its PVCs, only members leaving the cluster lose their data
+	pp := c.memberPodPolicy(toRemove)
+	if isScalingEvent && pp != nil && pp.PersistentVolumeClaimSpec != nil {
 		err = c.removePVC(k8sutil.PVCNameFromMember(toRemove.Name))
 		if err != nil {
 			return err
 		}
 	}
-	*/
-	c.logger.Infof("removed member (%v) with ID (%d)", toRemove.Name, toRemove.ID)
+	if isScalingEvent && pp != nil && pp.TlogPersistentVolumeClaimSpec != nil {
+		err = c.removePVC(k8sutil.TlogPVCNameFromMember(toRemove.Name))
+		if err != nil {
+			return err
+		}
+	}
+	c.logger.Infof("removed member (%v) with ID (%d)", toRemove.Name, toRemove.ID())
 	return nil
 }
 
@@ -222,16 +364,90 @@ func (c *Cluster) removePVC(pvcName string) error {
 	return nil
 }
 
+// removeOrphanPVCs deletes the PVCs of members that are no longer part of the cluster,
+// e.g. when deleting them failed during a scale down.
+func (c *Cluster) removeOrphanPVCs() error {
+	pvcs, err := c.config.KubeCli.Core().PersistentVolumeClaims(c.cluster.Namespace).List(k8sutil.ClusterListOpt(c.cluster.Name))
+	if err != nil {
+		return fmt.Errorf("failed to list PVCs: %v", err)
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.DeletionTimestamp != nil {
+			continue
+		}
+		if len(pvc.OwnerReferences) < 1 || pvc.OwnerReferences[0].UID != c.cluster.UID {
+			continue
+		}
+		if _, ok := c.members[pvc.Labels["zookeeper_node"]]; ok {
+			continue
+		}
+		c.logger.Infof("removing orphan PVC (%s)", pvc.Name)
+		if err := c.removePVC(pvc.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clusterPods returns the number of pods of the cluster spec, participants and observers.
+func clusterPods(cs api.ClusterSpec) int {
+	return cs.Size + cs.Observers.Size()
+}
+
 func needUpgrade(pods []*v1.Pod, cs api.ClusterSpec) bool {
-	return len(pods) == cs.Size && pickOneOldMember(pods, cs.Version) != nil
+	return len(pods) == clusterPods(cs) && len(oldMembers(pods, cs)) != 0
 }
 
-func pickOneOldMember(pods []*v1.Pod, newVersion string) *zookeeperutil.Member {
+// oldMembers returns the members that do not run the version of the spec.
+func oldMembers(pods []*v1.Pod, cs api.ClusterSpec) []*zookeeperutil.Member {
+	var ms []*zookeeperutil.Member
 	for _, pod := range pods {
-		if k8sutil.GetZookeeperVersion(pod) == newVersion {
+		if k8sutil.GetZookeeperVersion(pod) == cs.Version {
 			continue
 		}
-		return &zookeeperutil.Member{Name: pod.Name, Namespace: pod.Namespace}
+		ms = append(ms, &zookeeperutil.Member{Name: pod.Name, Namespace: pod.Namespace})
 	}
-	return nil
+	return ms
+}
+
+// imageChangedMembers returns the members that run the version of the spec with another
+// image, e.g. after the image was pinned by digest or rebuilt under the same version.
+func imageChangedMembers(pods []*v1.Pod, cs api.ClusterSpec) []*zookeeperutil.Member {
+	image := k8sutil.ZookeeperImage(cs)
+	var ms []*zookeeperutil.Member
+	for _, pod := range pods {
+		if k8sutil.GetZookeeperVersion(pod) != cs.Version || k8sutil.GetZookeeperImage(pod) == image {
+			continue
+		}
+		ms = append(ms, &zookeeperutil.Member{Name: pod.Name, Namespace: pod.Namespace})
+	}
+	return ms
+}
+
+func needRollout(pods []*v1.Pod, cs api.ClusterSpec, hashes podTemplateHashes) bool {
+	return len(pods) == clusterPods(cs) && len(staleMembers(pods, hashes)) != 0
+}
+
+// staleMembers returns the members whose pod was not created from the pod template
+// with the hash of their role. Pods created before the template hash was introduced
+// have no hash and are stale, since their template is unknown.
+func staleMembers(pods []*v1.Pod, hashes podTemplateHashes) []*zookeeperutil.Member {
+	var ms []*zookeeperutil.Member
+	for _, pod := range pods {
+		if k8sutil.GetZookeeperPodTemplateHash(pod) == hashes.of(pod) {
+			continue
+		}
+		ms = append(ms, &zookeeperutil.Member{Name: pod.Name, Namespace: pod.Namespace})
+	}
+	return ms
+}
+
+func allPodsReady(pods []*v1.Pod) bool {
+	for _, pod := range pods {
+		if !k8sutil.IsPodReady(pod) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,284 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hashedPod returns a member pod created from the pod template with the given hash. An
+// empty hash leaves the annotation out, like on pods of older operator versions.
+func hashedPod(name, hash string, observer bool) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+	}}
+	if len(hash) != 0 {
+		pod.Annotations["zookeeper.podtemplate.hash"] = hash
+	}
+	if observer {
+		pod.Labels["zookeeper_role"] = "observer"
+	}
+	return pod
+}
+
+func TestStaleMembers(t *testing.T) {
+	hashes := podTemplateHashes{participant: "p1", observer: "o1"}
+	tests := []struct {
+		pods   []*v1.Pod
+		wStale []string
+	}{{
+		pods:   []*v1.Pod{hashedPod("test-1", "p1", false), hashedPod("test-2", "p1", false)},
+		wStale: nil,
+	}, {
+		pods:   []*v1.Pod{hashedPod("test-1", "p0", false), hashedPod("test-2", "p1", false)},
+		wStale: []string{"test-1"},
+	}, {
+		// The template of pods without a hash is unknown.
+		pods:   []*v1.Pod{hashedPod("test-1", "", false), hashedPod("test-2", "p1", false)},
+		wStale: []string{"test-1"},
+	}, {
+		// Observers are compared with the template of the observers.
+		pods:   []*v1.Pod{hashedPod("test-1", "p1", false), hashedPod("test-2", "o1", true), hashedPod("test-3", "p1", true)},
+		wStale: []string{"test-3"},
+	}}
+	for i, tt := range tests {
+		var stale []string
+		for _, m := range staleMembers(tt.pods, hashes) {
+			stale = append(stale, m.Name)
+		}
+		if !reflect.DeepEqual(stale, tt.wStale) {
+			t.Errorf("#%d: stale members get=%v, want=%v", i, stale, tt.wStale)
+		}
+	}
+}
+
+func TestNeedRollout(t *testing.T) {
+	hashes := podTemplateHashes{participant: "p1", observer: "o1"}
+	tests := []struct {
+		pods     []*v1.Pod
+		cs       api.ClusterSpec
+		wRollout bool
+	}{{
+		pods:     []*v1.Pod{hashedPod("test-1", "p1", false), hashedPod("test-2", "p1", false)},
+		cs:       api.ClusterSpec{Size: 2},
+		wRollout: false,
+	}, {
+		pods:     []*v1.Pod{hashedPod("test-1", "p0", false), hashedPod("test-2", "p1", false)},
+		cs:       api.ClusterSpec{Size: 2},
+		wRollout: true,
+	}, {
+		// Members are only rolled once the cluster has its size.
+		pods:     []*v1.Pod{hashedPod("test-1", "p0", false)},
+		cs:       api.ClusterSpec{Size: 2},
+		wRollout: false,
+	}, {
+		pods:     []*v1.Pod{hashedPod("test-1", "p1", false), hashedPod("test-2", "", true)},
+		cs:       api.ClusterSpec{Size: 1, Observers: &api.ObserverPolicy{Count: 1}},
+		wRollout: true,
+	}}
+	for i, tt := range tests {
+		if rollout := needRollout(tt.pods, tt.cs, hashes); rollout != tt.wRollout {
+			t.Errorf("#%d: rollout get=%v, want=%v", i, rollout, tt.wRollout)
+		}
+	}
+}
+
+func TestOldAndImageChangedMembers(t *testing.T) {
+	cs := api.ClusterSpec{Size: 3, Version: "3.5.4-beta", Repository: "zookeeper"}
+	newPod := func(name, version, image string) *v1.Pod {
+		pod := hashedPod(name, "p1", false)
+		k8sutil.SetZookeeperVersion(pod, version)
+		pod.Spec.Containers = []v1.Container{{Name: "zookeeper", Image: image}}
+		return pod
+	}
+	names := func(ms []*zookeeperutil.Member) []string {
+		var res []string
+		for _, m := range ms {
+			res = append(res, m.Name)
+		}
+		return res
+	}
+	tests := []struct {
+		name           string
+		pods           []*v1.Pod
+		wOld, wChanged []string
+	}{{
+		name: "up to date",
+		pods: []*v1.Pod{newPod("test-1", "3.5.4-beta", "zookeeper:v3.5.4-beta")},
+	}, {
+		name: "version upgrade changes the image as well",
+		pods: []*v1.Pod{
+			newPod("test-1", "3.5.3-beta", "zookeeper:v3.5.3-beta"),
+			newPod("test-2", "3.5.4-beta", "zookeeper:v3.5.4-beta"),
+		},
+		wOld: []string{"test-1"},
+	}, {
+		name: "image only",
+		pods: []*v1.Pod{
+			newPod("test-1", "3.5.4-beta", "mirror/zookeeper:v3.5.4-beta"),
+			newPod("test-2", "3.5.4-beta", "zookeeper:v3.5.4-beta"),
+		},
+		wChanged: []string{"test-1"},
+	}}
+	for _, tt := range tests {
+		if old := names(oldMembers(tt.pods, cs)); !reflect.DeepEqual(old, tt.wOld) {
+			t.Errorf("%s: old members get=%v, want=%v", tt.name, old, tt.wOld)
+		}
+		if changed := names(imageChangedMembers(tt.pods, cs)); !reflect.DeepEqual(changed, tt.wChanged) {
+			t.Errorf("%s: image changed members get=%v, want=%v", tt.name, changed, tt.wChanged)
+		}
+	}
+}
+
+func TestRemoveOrphanPVCs(t *testing.T) {
+	c := newPVCTestCluster()
+	pvcs := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace)
+	// The cluster was scaled down from 5 to 3 members, but deleting the PVCs of the
+	// removed members failed.
+	c.members = zookeeperutil.MemberSet{}
+	for _, name := range []string{"test-1", "test-2", "test-3", "test-4", "test-5"} {
+		m := c.newMemberNamed(name)
+		if _, err := c.newMemberPod(nil, m, "new"); err != nil {
+			t.Fatal(err)
+		}
+		if name <= "test-3" {
+			c.members.Add(m)
+		}
+	}
+	other := k8sutil.NewZookeeperPodPVC(c.newMemberNamed("test-6"), *c.cluster.Spec.Pod.PersistentVolumeClaimSpec, "test", c.cluster.Namespace,
+		metav1.OwnerReference{UID: "uid-previous-cluster"})
+	if _, err := pvcs.Create(other); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.removeOrphanPVCs(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		member string
+		wKept  bool
+	}{
+		{member: "test-1", wKept: true},
+		{member: "test-3", wKept: true},
+		{member: "test-4", wKept: false},
+		{member: "test-5", wKept: false},
+	} {
+		name := k8sutil.PVCNameFromMember(tt.member)
+		_, err := pvcs.Get(name, metav1.GetOptions{})
+		if (err == nil) != tt.wKept {
+			t.Errorf("PVC (%s) kept get=%v, want=%v", name, err == nil, tt.wKept)
+		}
+	}
+	if _, err := pvcs.Get(other.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the PVC not owned by the cluster to be kept: %v", err)
+	}
+}
+
+func TestRemoveReplacedMemberKeepsPVCs(t *testing.T) {
+	c := newPVCTestCluster()
+	m := c.newMemberNamed("test-2")
+	c.members = zookeeperutil.NewMemberSet(m)
+	if _, err := c.newMemberPod(nil, m, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.removeMember(m, false); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace).Get(k8sutil.PVCNameFromMember(m.Name), metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("expected the PVC of a replaced member to be kept: %v", err)
+	}
+}
+
+func TestRemoveOrphanTlogPVCs(t *testing.T) {
+	c := newPVCTestCluster()
+	c.cluster.Spec.Pod.TlogPersistentVolumeClaimSpec = c.cluster.Spec.Pod.PersistentVolumeClaimSpec
+	kept, removed := c.newMemberNamed("test-1"), c.newMemberNamed("test-4")
+	c.members = zookeeperutil.NewMemberSet(kept)
+	for _, m := range []*zookeeperutil.Member{kept, removed} {
+		if _, err := c.newMemberPod(nil, m, "new"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.removeOrphanPVCs(); err != nil {
+		t.Fatal(err)
+	}
+	pvcs := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace)
+	if _, err := pvcs.Get(k8sutil.TlogPVCNameFromMember(kept.Name), metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the transaction log PVC of a member to be kept: %v", err)
+	}
+	if _, err := pvcs.Get(k8sutil.TlogPVCNameFromMember(removed.Name), metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the transaction log PVC of a removed member to be deleted")
+	}
+}
+
+func TestResizeAddsParticipantsBeforeObservers(t *testing.T) {
+	tests := []struct {
+		name                    string
+		participants, observers int
+		cs                      api.ClusterSpec
+		wAdded                  string
+		wObserver               bool
+	}{
+		{name: "scale up", participants: 3, cs: api.ClusterSpec{Size: 5}, wAdded: "test-4"},
+		{name: "add observer", participants: 3, cs: api.ClusterSpec{Size: 3, Observers: &api.ObserverPolicy{Count: 1}}, wAdded: "test-4", wObserver: true},
+		{name: "scale up before adding observers", participants: 3, cs: api.ClusterSpec{Size: 5, Observers: &api.ObserverPolicy{Count: 1}}, wAdded: "test-4"},
+		{name: "scale up with observers", participants: 3, observers: 1, cs: api.ClusterSpec{Size: 5, Observers: &api.ObserverPolicy{Count: 1}}, wAdded: "test-5"},
+		{name: "in size", participants: 3, observers: 1, cs: api.ClusterSpec{Size: 3, Observers: &api.ObserverPolicy{Count: 1}}},
+	}
+	for _, tt := range tests {
+		c := newRolloutTestCluster()
+		c.cluster.Spec = tt.cs
+		c.members = zookeeperutil.MemberSet{}
+		for i := 1; i <= tt.participants+tt.observers; i++ {
+			m := c.newMemberNamed(fmt.Sprintf("test-%d", i))
+			m.Observer = i > tt.participants
+			c.members.Add(m)
+		}
+
+		if err := c.resize(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		pods, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var added []string
+		var observer bool
+		for i := range pods.Items {
+			added = append(added, pods.Items[i].Name)
+			observer = k8sutil.IsObserverPod(&pods.Items[i])
+		}
+		var wAdded []string
+		if len(tt.wAdded) != 0 {
+			wAdded = []string{tt.wAdded}
+		}
+		if !reflect.DeepEqual(added, wAdded) || observer != tt.wObserver {
+			t.Errorf("%s: added get=%v (observer=%v), want=%v (observer=%v)", tt.name, added, observer, wAdded, tt.wObserver)
+		}
+	}
+}
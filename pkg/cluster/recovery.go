@@ -0,0 +1,285 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/backup"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/retryutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lostQuorum tells whether less than a majority of the participants have a running pod.
+func lostQuorum(members zookeeperutil.MemberSet, running []*v1.Pod) bool {
+	members = members.Participants()
+	n := 0
+	for _, pod := range running {
+		if _, ok := members[pod.Name]; ok {
+			n++
+		}
+	}
+	return n < members.Size()/2+1
+}
+
+// recoverQuorum brings back a cluster whose majority has been down for the grace period
+// of the recovery policy. The lost members are restarted one at a time, with the data of
+// their persistent volumes if they have any, otherwise they sync from the surviving
+// members. The cluster is only rebuilt from a backup if the recovery policy opts in and
+// neither a surviving member nor a persistent volume holds newer data. Members addressed
+// by pod IP cannot be restarted since the addresses of the lost members are gone with
+// their pods. A member the operator took down itself is restarted without waiting for
+// the grace period, even if it has neither a persistent volume nor a surviving member.
+func (c *Cluster) recoverQuorum(running []*v1.Pod) error {
+	rp := c.cluster.Spec.Recovery
+	survivors := len(participantPods(running))
+	restartable := c.cluster.Spec.MemberAddress.AddressType() != api.MemberAddressPodIP
+	rolled := c.lostRolledMember(running)
+	var msg string
+	var recoverFn func([]*v1.Pod) error
+	switch {
+	case restartable && rolled != nil:
+		// The operator took the member down itself, e.g. restarted the seed member of a
+		// single member cluster, so it brings it back right away.
+		msg = fmt.Sprintf("Restarting member %s the operator restarted", rolled.Name)
+		recoverFn = c.restartLostMembers
+	case restartable && c.isRolePVEnabled(false):
+		msg = "Restarting the lost members from their persistent volumes"
+		recoverFn = c.restartLostMembers
+	case restartable && survivors > 0:
+		msg = "Restarting the lost members from the surviving members"
+		recoverFn = c.restartLostMembers
+	case survivors == 0 && !c.isPodPVEnabled() && rp.IsRebuildFromBackupEnabled():
+		msg = fmt.Sprintf("Rebuilding the cluster from backup %s", rp.BackupName)
+		recoverFn = c.rebuildFromBackup
+	case survivors == 0 && c.isRolePVEnabled(true):
+		msg = "Only the observers have persistent volumes, which cannot bring back the quorum"
+	default:
+		msg = "No persistent volume, surviving member nor backup to recover from"
+	}
+
+	if c.quorumLostSince.IsZero() {
+		c.quorumLostSince = time.Now()
+		c.logger.Warningf("quorum lost: %s", msg)
+		size := c.members.Participants().Size()
+		if size == 0 {
+			size = c.status.Size
+		}
+		_, err := c.eventsCli.Create(k8sutil.QuorumLostEvent(len(participantPods(running)), size, msg, c.cluster))
+		if err != nil {
+			c.logger.Errorf("failed to create quorum lost event: %v", err)
+		}
+	}
+	if recoverFn == nil {
+		return ErrLostQuorum
+	}
+	// A member restarting may take the majority down for a moment.
+	if rolled == nil && time.Since(c.quorumLostSince) < rp.GracePeriod() {
+		c.status.SetRecoveringCondition(fmt.Sprintf("%s once the quorum is lost for %v", msg, rp.GracePeriod()))
+		return ErrLostQuorum
+	}
+	c.status.SetRecoveringCondition(msg)
+	return recoverFn(running)
+}
+
+// lostRolledMember returns the participant the operator last upgraded or restarted if its
+// pod is gone, or nil.
+func (c *Cluster) lostRolledMember(running []*v1.Pod) *zookeeperutil.Member {
+	if c.rollout == nil || c.members == nil {
+		return nil
+	}
+	m, ok := c.members.Participants()[c.rollout.name]
+	if !ok {
+		return nil
+	}
+	for _, pod := range running {
+		if pod.Name == m.Name {
+			return nil
+		}
+	}
+	return m
+}
+
+// quorumRecovered ends the recovery once a majority of the members runs again.
+func (c *Cluster) quorumRecovered() {
+	c.status.ClearCondition(api.ClusterConditionRecovering)
+	if c.quorumLostSince.IsZero() {
+		return
+	}
+	c.logger.Infof("quorum recovered after %v", time.Since(c.quorumLostSince))
+	c.quorumLostSince = time.Time{}
+	_, err := c.eventsCli.Create(k8sutil.QuorumRecoveredEvent(c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create quorum recovered event: %v", err)
+	}
+}
+
+// restartLostMembers recreates the pod of one member that is down, at most once per
+// restart interval of the recovery policy. The members keep their configuration since
+// the cluster cannot be reconfigured without quorum. They come back with the data of
+// their PVCs, if any, or sync from the surviving members.
+func (c *Cluster) restartLostMembers(running []*v1.Pod) error {
+	if c.members == nil {
+		// The operator restarted while the quorum was lost.
+		ms, err := c.membersFromPVCs(running)
+		if err != nil {
+			return err
+		}
+		c.members = ms
+	}
+	if time.Since(c.lastLostMemberRestart) < c.cluster.Spec.Recovery.RestartInterval() {
+		return nil
+	}
+	m := pickLostMember(c.members.Diff(c.podsToMemberSet(running)))
+	if m == nil {
+		return nil
+	}
+	// The pod of a member that is down may still exist, e.g. failed.
+	if err := c.removePod(m.Name, false); err != nil {
+		return err
+	}
+	others := c.members.Diff(zookeeperutil.NewMemberSet(m))
+	if err := c.createPod(others.ClusterConfig(c.cluster.Spec.TLS.IsPlainClientPortDisabled()), m, "replacement"); err != nil {
+		// A deleted pod may take a moment to go away, it is retried on the next reconciliation.
+		return fmt.Errorf("failed to restart member (%s): %v", m.Name, err)
+	}
+	c.lastLostMemberRestart = time.Now()
+	_, err := c.eventsCli.Create(k8sutil.MemberRestartEvent(m.Name, "quorum lost", c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create member restart event: %v", err)
+	}
+	return nil
+}
+
+// pickLostMember returns the lost member to restart next: participants first since only
+// they bring the quorum back, lowest ID first.
+func pickLostMember(lost zookeeperutil.MemberSet) *zookeeperutil.Member {
+	var picked *zookeeperutil.Member
+	for _, m := range lost {
+		if picked == nil || (picked.Observer && !m.Observer) ||
+			(picked.Observer == m.Observer && m.ID() < picked.ID()) {
+			picked = m
+		}
+	}
+	return picked
+}
+
+// membersFromPVCs returns the members that have a data PVC or a running pod.
+func (c *Cluster) membersFromPVCs(running []*v1.Pod) (zookeeperutil.MemberSet, error) {
+	pvcs, err := c.config.KubeCli.CoreV1().PersistentVolumeClaims(c.cluster.Namespace).List(k8sutil.ClusterListOpt(c.cluster.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %v", err)
+	}
+	ms := c.podsToMemberSet(running)
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.DeletionTimestamp != nil || len(pvc.OwnerReferences) < 1 || pvc.OwnerReferences[0].UID != c.cluster.UID {
+			continue
+		}
+		name := pvc.Labels["zookeeper_node"]
+		if len(name) == 0 || pvc.Name != k8sutil.PVCNameFromMember(name) {
+			continue
+		}
+		if _, ok := ms[name]; ok {
+			continue
+		}
+		m := c.newMemberNamed(name)
+		m.Observer = k8sutil.IsObserverPVC(pvc)
+		ms.Add(m)
+	}
+	if ms.Size() == 0 {
+		return nil, fmt.Errorf("no member found to restart")
+	}
+	return ms, nil
+}
+
+// rebuildFromBackup replaces all the members with a seed member restored from the latest
+// backup. The cluster then grows back to its size.
+func (c *Cluster) rebuildFromBackup(running []*v1.Pod) error {
+	rp, err := c.rebuildRestorePolicy()
+	if err != nil {
+		return err
+	}
+
+	if err := c.removeAllPods(); err != nil {
+		return err
+	}
+	c.members = zookeeperutil.MemberSet{}
+	// The transaction logs of the lost members must not be replayed over the backup.
+	if err := c.removeOrphanPVCs(); err != nil {
+		return err
+	}
+
+	c.logger.Infof("rebuilding the cluster from backup %s", rp.Path())
+	_, err = c.eventsCli.Create(k8sutil.ClusterRebuildEvent(rp.Path(), c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create cluster rebuild event: %v", err)
+	}
+	c.status.SetScalingUpCondition(0, c.cluster.Spec.Size)
+	if err := c.startSeedMember(rp, true); err != nil {
+		// Start over on the next reconciliation.
+		if rerr := c.removeAllPods(); rerr != nil {
+			c.logger.Warningf("failed to remove the seed member: %v", rerr)
+		}
+		c.members = zookeeperutil.MemberSet{}
+		return err
+	}
+	return nil
+}
+
+// rebuildRestorePolicy returns the restore policy of the latest backup of the recovery
+// policy.
+func (c *Cluster) rebuildRestorePolicy() (*api.RestorePolicy, error) {
+	if !c.cluster.Spec.Recovery.IsRebuildFromBackupEnabled() {
+		return nil, errors.New("rebuilding from a backup is disabled")
+	}
+	name := c.cluster.Spec.Recovery.BackupName
+	b, err := c.config.ZookeeperCRCli.ZookeeperV1alpha1().ZookeeperBackups(c.cluster.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup (%s): %v", name, err)
+	}
+	if b.Spec.ClusterName != c.cluster.Name {
+		return nil, fmt.Errorf("backup (%s) is a backup of cluster (%s)", name, b.Spec.ClusterName)
+	}
+	return backup.LatestRestorePolicy(b)
+}
+
+// removeAllPods deletes the pods of the cluster and waits until they are gone.
+func (c *Cluster) removeAllPods() error {
+	ns := c.cluster.Namespace
+	podList, err := c.config.KubeCli.CoreV1().Pods(ns).List(k8sutil.ClusterListOpt(c.cluster.Name))
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %v", err)
+	}
+	for i := range podList.Items {
+		if err := c.removePod(podList.Items[i].Name, false); err != nil {
+			return err
+		}
+	}
+	return retryutil.Retry(2*time.Second, 30, func() (bool, error) {
+		podList, err := c.config.KubeCli.CoreV1().Pods(ns).List(k8sutil.ClusterListOpt(c.cluster.Name))
+		if err != nil {
+			return false, err
+		}
+		return len(podList.Items) == 0, nil
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLostQuorum(t *testing.T) {
+	members := zookeeperutil.NewMemberSet(
+		&zookeeperutil.Member{Name: "test-1"},
+		&zookeeperutil.Member{Name: "test-2"},
+		&zookeeperutil.Member{Name: "test-3"},
+		&zookeeperutil.Member{Name: "test-4", Observer: true},
+		&zookeeperutil.Member{Name: "test-5", Observer: true},
+	)
+	pods := func(names ...string) []*v1.Pod {
+		var ps []*v1.Pod
+		for _, name := range names {
+			ps = append(ps, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+		return ps
+	}
+	tests := []struct {
+		running []*v1.Pod
+		wLost   bool
+	}{{
+		running: pods("test-1", "test-2", "test-3"),
+		wLost:   false,
+	}, {
+		running: pods("test-1", "test-3"),
+		wLost:   false,
+	}, {
+		running: pods("test-2"),
+		wLost:   true,
+	}, {
+		// Pods that are not members do not count.
+		running: pods("test-2", "test-6"),
+		wLost:   true,
+	}, {
+		// Observers do not vote.
+		running: pods("test-2", "test-4", "test-5"),
+		wLost:   true,
+	}, {
+		running: pods("test-1", "test-2"),
+		wLost:   false,
+	}, {
+		running: nil,
+		wLost:   true,
+	}}
+	for i, tt := range tests {
+		if lost := lostQuorum(members, tt.running); lost != tt.wLost {
+			t.Errorf("#%d: lost quorum get=%v, want=%v", i, lost, tt.wLost)
+		}
+	}
+}
+
+func TestPickLostMember(t *testing.T) {
+	tests := []struct {
+		lost    zookeeperutil.MemberSet
+		wPicked string
+	}{{
+		lost:    zookeeperutil.NewMemberSet(),
+		wPicked: "",
+	}, {
+		lost: zookeeperutil.NewMemberSet(
+			&zookeeperutil.Member{Name: "test-3"},
+			&zookeeperutil.Member{Name: "test-2"},
+		),
+		wPicked: "test-2",
+	}, {
+		// Participants bring the quorum back, observers do not.
+		lost: zookeeperutil.NewMemberSet(
+			&zookeeperutil.Member{Name: "test-1", Observer: true},
+			&zookeeperutil.Member{Name: "test-5"},
+		),
+		wPicked: "test-5",
+	}, {
+		lost: zookeeperutil.NewMemberSet(
+			&zookeeperutil.Member{Name: "test-4", Observer: true},
+			&zookeeperutil.Member{Name: "test-3", Observer: true},
+		),
+		wPicked: "test-3",
+	}}
+	for i, tt := range tests {
+		picked := ""
+		if m := pickLostMember(tt.lost); m != nil {
+			picked = m.Name
+		}
+		if picked != tt.wPicked {
+			t.Errorf("#%d: picked get=%q, want=%q", i, picked, tt.wPicked)
+		}
+	}
+}
+
+func TestFinishRestore(t *testing.T) {
+	tests := []struct {
+		name     string
+		rebuild  bool
+		err      error
+		wPhase   api.RestorePhase
+		wPod     bool
+		wFailure bool
+	}{{
+		name:   "restored",
+		err:    nil,
+		wPhase: api.RestorePhaseSucceeded,
+		wPod:   true,
+	}, {
+		name:     "new cluster fails",
+		err:      errors.New("no such backup"),
+		wPhase:   api.RestorePhaseFailed,
+		wFailure: true,
+	}, {
+		name:    "rebuild starts over",
+		rebuild: true,
+		err:     errors.New("no such backup"),
+		wPhase:  api.RestorePhaseFailed,
+	}}
+	for _, tt := range tests {
+		c := newRolloutTestCluster()
+		seed := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-1", Namespace: c.cluster.Namespace}}
+		if _, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Create(seed); err != nil {
+			t.Fatal(err)
+		}
+		c.members = zookeeperutil.NewMemberSet(&zookeeperutil.Member{Name: "test-1"})
+		c.status.Restore = &api.RestoreStatus{Member: "test-1", Rebuild: tt.rebuild, Phase: api.RestorePhaseRunning}
+		c.restoreDone = make(chan error, 1)
+
+		err := c.finishRestore(tt.err)
+		if (err != nil) != tt.wFailure {
+			t.Errorf("%s: cluster failure get=%v, want=%v", tt.name, err, tt.wFailure)
+		}
+		if c.restoreDone != nil {
+			t.Errorf("%s: restore still running", tt.name)
+		}
+		if c.status.Restore.Phase != tt.wPhase {
+			t.Errorf("%s: phase get=%s, want=%s", tt.name, c.status.Restore.Phase, tt.wPhase)
+		}
+		_, err = c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Get("test-1", metav1.GetOptions{})
+		if (err == nil) != tt.wPod {
+			t.Errorf("%s: seed pod kept get=%v, want=%v", tt.name, err == nil, tt.wPod)
+		}
+		if (c.members.Size() == 1) != tt.wPod {
+			t.Errorf("%s: members get=%v", tt.name, c.members)
+		}
+	}
+}
+
+func TestRecoverQuorumRestartsRolledMember(t *testing.T) {
+	c := newRolloutTestCluster()
+	c.cluster.Spec.Size = 1
+	c.members = zookeeperutil.NewMemberSet(c.newMemberNamed("test-1"))
+	// The operator restarted the only member, which has no persistent volume.
+	rolledPod(c, "test-1", 0)
+
+	if err := c.recoverQuorum(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Get("test-1", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the restarted member to be recreated: %v", err)
+	}
+
+	c = newRolloutTestCluster()
+	c.cluster.Spec.Size = 1
+	c.members = zookeeperutil.NewMemberSet(c.newMemberNamed("test-1"))
+	if err := c.recoverQuorum(nil); err != ErrLostQuorum {
+		t.Errorf("err get=%v, want=%v", err, ErrLostQuorum)
+	}
+}
+
+func TestRecoverQuorumObserverPVs(t *testing.T) {
+	c := newPVCTestCluster()
+	c.cluster.Spec.Observers = &api.ObserverPolicy{Count: 1, Pod: c.cluster.Spec.Pod}
+	c.cluster.Spec.Pod = nil
+	c.cluster.Spec.Recovery = &api.RecoveryPolicy{RebuildFromBackup: true, BackupName: "example-backup"}
+	if !c.isPodPVEnabled() || c.isRolePVEnabled(false) {
+		t.Fatalf("expected only the observers to have persistent volumes")
+	}
+	c.members = zookeeperutil.NewMemberSet(c.newMemberNamed("test-1"))
+	c.quorumLostSince = time.Now().Add(-time.Hour)
+
+	// The data of the observers must not be replaced with a backup.
+	if err := c.recoverQuorum(nil); err != ErrLostQuorum {
+		t.Errorf("err get=%v, want=%v", err, ErrLostQuorum)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+)
+
+// pollMemberSecrets reads the TLS and SASL secrets of the cluster and notices when the
+// ones the members read at startup changed, so that the members get restarted.
+// The SASL secret is rendered even if the TLS secrets cannot be read, since new pods
+// mount it.
+func (c *Cluster) pollMemberSecrets() error {
+	tlsSecrets, tlsErr := c.pollTLSSecrets()
+	saslSecret, saslErr := c.syncSASLSecret()
+	if tlsErr != nil {
+		return tlsErr
+	}
+	if saslErr != nil {
+		return saslErr
+	}
+
+	restartFor := tlsSecrets
+	if saslSecret != nil {
+		restartFor = append(restartFor, saslSecret)
+	}
+	if len(restartFor) == 0 {
+		c.memberSecretsHash = ""
+		return nil
+	}
+	hash := k8sutil.SecretsHash(restartFor...)
+	if len(c.memberSecretsHash) != 0 && hash != c.memberSecretsHash {
+		c.logger.Infof("member secrets changed, restarting the members")
+		_, err := c.eventsCli.Create(k8sutil.MemberSecretsChangedEvent(c.cluster))
+		if err != nil {
+			c.logger.Errorf("failed to create member secrets changed event: %v", err)
+		}
+	}
+	c.memberSecretsHash = hash
+	return nil
+}
+
+// changedSecretsMembers returns the members whose pod was created with secrets that
+// changed since. Pods created before the secrets were tracked may run with stale ones,
+// so they count as changed.
+func (c *Cluster) changedSecretsMembers(pods []*v1.Pod) []*zookeeperutil.Member {
+	if len(c.memberSecretsHash) == 0 {
+		return nil
+	}
+	var ms []*zookeeperutil.Member
+	for _, pod := range pods {
+		hash := k8sutil.GetMemberSecretsHash(pod)
+		if hash == c.memberSecretsHash {
+			continue
+		}
+		ms = append(ms, &zookeeperutil.Member{Name: pod.Name, Namespace: pod.Namespace})
+	}
+	return ms
+}
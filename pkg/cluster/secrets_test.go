@@ -0,0 +1,48 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cluster
+
+import (
+	"testing"
+
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestChangedSecretsMembers(t *testing.T) {
+	newPod := func(name, hash string) *v1.Pod {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}}}
+		if len(hash) != 0 {
+			k8sutil.SetMemberSecretsHash(pod, hash)
+		}
+		return pod
+	}
+	pods := []*v1.Pod{newPod("test-1", "s1"), newPod("test-2", "s0"), newPod("test-3", "")}
+
+	c := &Cluster{}
+	if ms := c.changedSecretsMembers(pods); len(ms) != 0 {
+		t.Errorf("expected no member to restart without member secrets, got %v", ms)
+	}
+
+	c.memberSecretsHash = "s1"
+	var names []string
+	for _, m := range c.changedSecretsMembers(pods) {
+		names = append(names, m.Name)
+	}
+	if len(names) != 2 || names[0] != "test-2" || names[1] != "test-3" {
+		t.Errorf("changed members get=%v, want=[test-2 test-3]", names)
+	}
+}
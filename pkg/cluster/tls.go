@@ -0,0 +1,211 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// certExpiryWarningInterval is how often a certificate about to expire is warned about.
+const certExpiryWarningInterval = 24 * time.Hour
+
+// clientPort is the port the operator talks to the members on. Once the plain client
+// port only listens on localhost the operator goes through the secure client port.
+func (c *Cluster) clientPort() int {
+	if c.cluster.Spec.TLS.IsPlainClientPortDisabled() {
+		return k8sutil.ZookeeperSecureClientPort
+	}
+	return k8sutil.ZookeeperClientPort
+}
+
+// clientTLSConfig returns the TLS config to talk to the members with, or nil if
+// the operator uses the plain client port. The operator secret is read again after
+// each poll of the TLS secrets, so that a rotated one is picked up.
+func (c *Cluster) clientTLSConfig() (*tls.Config, error) {
+	if !c.cluster.Spec.TLS.IsPlainClientPortDisabled() {
+		return nil, nil
+	}
+	if c.tlsConfig != nil {
+		return c.tlsConfig, nil
+	}
+	tlsConfig, err := k8sutil.GetOperatorTLSConfig(c.config.KubeCli, c.cluster.Namespace, c.cluster.Spec.TLS)
+	if err != nil {
+		return nil, err
+	}
+	c.tlsConfig = tlsConfig
+	return tlsConfig, nil
+}
+
+// checkSecureClientServed returns an error unless every member pod serves the secure client
+// port. The operator moves to the secure client port as soon as the plain one is disabled,
+// so the members must have rolled out the server secret before.
+func (c *Cluster) checkSecureClientServed() error {
+	if !c.cluster.Spec.TLS.IsSecureClient() {
+		return errors.New("the server secret is not set")
+	}
+	running, pending, err := c.pollPods()
+	if err != nil {
+		return err
+	}
+	if len(pending) != 0 {
+		return fmt.Errorf("members %v are pending", k8sutil.GetPodNames(pending))
+	}
+	for _, pod := range running {
+		if !k8sutil.HasSecureClientPort(pod) {
+			return fmt.Errorf("member (%s) does not serve the secure client port yet", pod.Name)
+		}
+	}
+	return nil
+}
+
+// clientHosts returns the client addresses of the given members together with the
+// config to connect to them.
+func (c *Cluster) clientHosts(ms zookeeperutil.MemberSet) ([]string, zookeeperutil.ConnConfig, error) {
+	var cc zookeeperutil.ConnConfig
+	tlsConfig, err := c.clientTLSConfig()
+	if err != nil {
+		return nil, cc, err
+	}
+	superPassword, err := c.superUserPassword()
+	if err != nil {
+		return nil, cc, err
+	}
+	cc.TLSConfig, cc.SuperPassword = tlsConfig, superPassword
+	hosts := zookeeperutil.MemberSet{}
+	for _, m := range ms {
+		hosts.Add(operatorAddressed(m))
+	}
+	return hosts.ClientHostList(c.clientPort()), cc, nil
+}
+
+// podTemplateHashes are the hashes of the pod templates of the participants and of the
+// observers.
+type podTemplateHashes struct {
+	participant string
+	observer    string
+}
+
+// of returns the hash of the pod template the pod should have been created from.
+func (h podTemplateHashes) of(pod *v1.Pod) string {
+	if k8sutil.IsObserverPod(pod) {
+		return h.observer
+	}
+	return h.participant
+}
+
+// podTemplateHashes returns the hashes of the pod templates new participants and
+// observers are created from.
+func (c *Cluster) podTemplateHashes() (podTemplateHashes, error) {
+	var h podTemplateHashes
+	var err error
+	h.participant, err = k8sutil.PodTemplateHash(k8sutil.MemberSpec(c.cluster.Spec, false), c.status.QuorumTLSPhase, c.status.QuorumSASLPhase)
+	if err != nil {
+		return h, err
+	}
+	h.observer, err = k8sutil.PodTemplateHash(k8sutil.MemberSpec(c.cluster.Spec, true), c.status.QuorumTLSPhase, c.status.QuorumSASLPhase)
+	return h, err
+}
+
+// advanceQuorumTLSPhase moves the members to the next step of the migration of the
+// quorum traffic to TLS. The new phase is rolled out by restarting the members one
+// at a time, so that they can always reach the members not restarted yet.
+func (c *Cluster) advanceQuorumTLSPhase() {
+	phase := c.status.QuorumTLSPhase.Next()
+	c.logger.Infof("migrating the quorum to TLS: phase %q to %q", c.status.QuorumTLSPhase, phase)
+	c.status.QuorumTLSPhase = phase
+	_, err := c.eventsCli.Create(k8sutil.QuorumTLSPhaseEvent(phase, c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create quorum TLS phase event: %v", err)
+	}
+}
+
+// pollTLSSecrets reads the TLS secrets of the cluster. It records when their certificates
+// expire, warns about the ones about to expire and returns the secrets the members have
+// to be restarted for when they change. The operator secret is reloaded on its next use.
+func (c *Cluster) pollTLSSecrets() ([]*v1.Secret, error) {
+	tp := c.cluster.Spec.TLS
+	if !tp.IsSecureClient() && !tp.IsSecurePeer() {
+		c.status.TLSCertificates = nil
+		return nil, nil
+	}
+	c.tlsConfig = nil
+
+	var restartFor []*v1.Secret
+	var certs []api.CertificateStatus
+	for _, name := range tlsSecretNames(tp) {
+		secret, err := c.config.KubeCli.CoreV1().Secrets(c.cluster.Namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get TLS secret (%s): %v", name, err)
+		}
+		if name == tp.Static.Member.ServerSecret || (name == tp.Static.Member.PeerSecret && c.restartsForPeerSecret()) {
+			restartFor = append(restartFor, secret)
+		}
+
+		notAfter, ok, err := k8sutil.CertificateNotAfter(secret)
+		if err != nil {
+			c.logger.Warningf("failed to check certificate expiry: %v", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		certs = append(certs, api.CertificateStatus{Secret: name, NotAfter: notAfter.Format(time.RFC3339)})
+		c.warnCertExpiry(name, notAfter, tp.CertExpiryWarning())
+	}
+	c.status.TLSCertificates = certs
+	return restartFor, nil
+}
+
+// restartsForPeerSecret tells whether the members have to be restarted when the peer
+// secret changes. They do not use it before the migration of the quorum to TLS starts.
+func (c *Cluster) restartsForPeerSecret() bool {
+	return c.status.QuorumTLSPhase != api.QuorumTLSPhaseNone && !c.cluster.Spec.TLS.Static.ReloadPeerCerts
+}
+
+// tlsSecretNames returns the names of the TLS secrets of the policy, without duplicates.
+func tlsSecretNames(tp *api.TLSPolicy) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range []string{tp.Static.Member.ServerSecret, tp.Static.Member.PeerSecret, tp.Static.OperatorSecret} {
+		if len(name) != 0 && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (c *Cluster) warnCertExpiry(secret string, notAfter time.Time, warning time.Duration) {
+	if time.Until(notAfter) > warning || time.Since(c.certExpiryWarnings[secret]) < certExpiryWarningInterval {
+		return
+	}
+	c.logger.Warningf("certificate of secret (%s) expires at %v", secret, notAfter)
+	_, err := c.eventsCli.Create(k8sutil.CertificateExpiringEvent(secret, notAfter, c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create certificate expiring event: %v", err)
+		return
+	}
+	c.certExpiryWarnings[secret] = time.Now()
+}
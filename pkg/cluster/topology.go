@@ -0,0 +1,140 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// topologyDomains returns the domain of every node, and the domains of the nodes new
+// members can be scheduled on. Nodes without the topology key are left out.
+func (c *Cluster) topologyDomains() (map[string]string, []string, error) {
+	key := c.cluster.Spec.Topology.TopologyKey
+	nodes, err := c.config.KubeCli.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	nodeDomains := map[string]string{}
+	schedulable := map[string]bool{}
+	for _, n := range nodes.Items {
+		domain, ok := n.Labels[key]
+		if !ok {
+			continue
+		}
+		nodeDomains[n.Name] = domain
+		if k8sutil.IsNodeReady(n) && !n.Spec.Unschedulable {
+			schedulable[domain] = true
+		}
+	}
+	var domains []string
+	for d := range schedulable {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return nodeDomains, domains, nil
+}
+
+// membersPerDomain counts the pods scheduled in each domain.
+func membersPerDomain(pods []*v1.Pod, nodeDomains map[string]string) map[string]int {
+	counts := map[string]int{}
+	for _, pod := range pods {
+		if domain, ok := nodeDomains[pod.Spec.NodeName]; ok {
+			counts[domain]++
+		}
+	}
+	return counts
+}
+
+// leastPopulatedDomain returns the domain with the fewest members, the first one in
+// order on a tie.
+func leastPopulatedDomain(domains []string, counts map[string]int) string {
+	least := ""
+	for _, d := range domains {
+		if len(least) == 0 || counts[d] < counts[least] {
+			least = d
+		}
+	}
+	return least
+}
+
+// majorityDomain returns the domain holding a majority of the members of an ensemble of
+// the given size, if any.
+func majorityDomain(counts map[string]int, size int) (string, bool) {
+	for d, n := range counts {
+		if n > size/2 {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// steerToLeastPopulatedDomain makes the pod of a member prefer the domain with the fewest
+// other members. Participants and observers are spread separately.
+func (c *Cluster) steerToLeastPopulatedDomain(pod *v1.Pod) error {
+	nodeDomains, domains, err := c.topologyDomains()
+	if err != nil {
+		return err
+	}
+	if len(domains) == 0 {
+		c.logger.Warningf("no schedulable node has the topology key %s, member (%s) is not steered", c.cluster.Spec.Topology.TopologyKey, pod.Name)
+		return nil
+	}
+	pods, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).List(k8sutil.ClusterListOpt(c.cluster.Name))
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %v", err)
+	}
+	var scheduled []*v1.Pod
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		// The pod of a replaced member may still be there.
+		if p.DeletionTimestamp == nil && p.Name != pod.Name && k8sutil.IsObserverPod(p) == k8sutil.IsObserverPod(pod) {
+			scheduled = append(scheduled, p)
+		}
+	}
+	domain := leastPopulatedDomain(domains, membersPerDomain(scheduled, nodeDomains))
+	c.logger.Infof("placing member (%s) in domain %s", pod.Name, domain)
+	k8sutil.PreferTopologyDomainForPod(pod, c.cluster.Spec.Topology.TopologyKey, domain)
+	return nil
+}
+
+// updateTopologyStatus reports the participants per domain, and warns when a single
+// domain holds a majority of them.
+func (c *Cluster) updateTopologyStatus(running []*v1.Pod) error {
+	if c.cluster.Spec.Topology == nil {
+		c.status.Topology = nil
+		c.status.ClearCondition(api.ClusterConditionTopologySkewed)
+		return nil
+	}
+	nodeDomains, _, err := c.topologyDomains()
+	if err != nil {
+		return err
+	}
+	counts := membersPerDomain(participantPods(running), nodeDomains)
+	c.status.Topology = counts
+	size := c.members.Participants().Size()
+	if domain, ok := majorityDomain(counts, size); ok && size > 1 {
+		c.status.SetTopologySkewedCondition(domain, counts[domain], size)
+	} else {
+		c.status.ClearCondition(api.ClusterConditionTopologySkewed)
+	}
+	return nil
+}
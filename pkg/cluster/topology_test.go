@@ -0,0 +1,64 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestLeastPopulatedDomain(t *testing.T) {
+	nodeDomains := map[string]string{"node-a": "zone-a", "node-b": "zone-b", "node-c": "zone-c"}
+	pods := func(nodes ...string) []*v1.Pod {
+		var ps []*v1.Pod
+		for _, node := range nodes {
+			ps = append(ps, &v1.Pod{Spec: v1.PodSpec{NodeName: node}})
+		}
+		return ps
+	}
+	domains := []string{"zone-a", "zone-b", "zone-c"}
+	tests := []struct {
+		pods    []*v1.Pod
+		wDomain string
+	}{{
+		pods:    nil,
+		wDomain: "zone-a",
+	}, {
+		pods:    pods("node-a"),
+		wDomain: "zone-b",
+	}, {
+		pods:    pods("node-a", "node-b", "node-a"),
+		wDomain: "zone-c",
+	}, {
+		// Pods on nodes without the topology key do not count.
+		pods:    pods("node-a", "node-b", "node-x"),
+		wDomain: "zone-c",
+	}}
+	for i, tt := range tests {
+		if d := leastPopulatedDomain(domains, membersPerDomain(tt.pods, nodeDomains)); d != tt.wDomain {
+			t.Errorf("#%d: domain get=%q, want=%q", i, d, tt.wDomain)
+		}
+	}
+}
+
+func TestMajorityDomain(t *testing.T) {
+	if d, ok := majorityDomain(map[string]int{"zone-a": 2, "zone-b": 1}, 3); !ok || d != "zone-a" {
+		t.Errorf("expected zone-a to hold a majority of 3, got %q %v", d, ok)
+	}
+	if d, ok := majorityDomain(map[string]int{"zone-a": 2, "zone-b": 2, "zone-c": 1}, 5); ok {
+		t.Errorf("expected no majority domain of 5, got %q", d)
+	}
+}
@@ -15,18 +15,26 @@
 package cluster
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/versionutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
 
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// upgradeOneMember moves the given member to the version of the spec. Moving it to an
+// older version rolls it back.
 func (c *Cluster) upgradeOneMember(memberName string) error {
-	c.status.SetUpgradingCondition(c.cluster.Spec.Version)
-
 	ns := c.cluster.Namespace
 
 	pod, err := c.config.KubeCli.CoreV1().Pods(ns).Get(memberName, metav1.GetOptions{})
@@ -34,25 +42,470 @@ func (c *Cluster) upgradeOneMember(memberName string) error {
 		return fmt.Errorf("fail to get pod (%s): %v", memberName, err)
 	}
 	oldpod := pod.DeepCopy()
+	oldVersion := k8sutil.GetZookeeperVersion(oldpod)
+	// The version of pods from before the version validation may not parse, moving
+	// them is considered an upgrade.
+	rollback, _ := versionutil.IsDowngrade(oldVersion, c.cluster.Spec.Version)
+	if rollback {
+		c.status.SetRollingBackCondition(c.cluster.Spec.Version)
+	} else {
+		c.status.SetUpgradingCondition(c.cluster.Spec.Version)
+	}
+	c.rollout = newMemberRollout(pod)
+
+	c.logger.Infof("upgrading the zookeeper member %v from %s to %s", memberName, oldVersion, c.cluster.Spec.Version)
+	hashes, err := c.podTemplateHashes()
+	if err != nil {
+		return err
+	}
+	// The pod has to be recreated for the pod template anyway, the replacement gets the
+	// new image at the same time. A member the ensemble cannot spare is upgraded in place
+	// instead, so it keeps its data, and is recreated once the ensemble can spare it.
+	recreate := k8sutil.GetZookeeperPodTemplateHash(pod) != hashes.of(pod)
+	if recreate {
+		if err := c.checkQuorumWithout(memberName); err != nil {
+			c.logger.Infof("upgrading the zookeeper member %v in place: %v", memberName, err)
+			recreate = false
+		}
+	}
+	if recreate {
+		if err := c.removePod(memberName, true); err != nil {
+			return fmt.Errorf("fail to recreate the zookeeper member (%s): %v", memberName, err)
+		}
+	} else {
+		pod.Spec.Containers[0].Image = k8sutil.ZookeeperImage(c.cluster.Spec)
+		k8sutil.SetZookeeperVersion(pod, c.cluster.Spec.Version)
+
+		patchdata, err := k8sutil.CreatePatch(oldpod, pod, v1.Pod{})
+		if err != nil {
+			return fmt.Errorf("error creating patch: %v", err)
+		}
+
+		_, err = c.config.KubeCli.CoreV1().Pods(ns).Patch(pod.GetName(), types.StrategicMergePatchType, patchdata)
+		if err != nil {
+			return fmt.Errorf("fail to update the zookeeper member (%s): %v", memberName, err)
+		}
+	}
+	c.logger.Infof("finished upgrading the zookeeper member %v", memberName)
+	event := k8sutil.MemberUpgradedEvent(memberName, oldVersion, c.cluster.Spec.Version, c.cluster)
+	if rollback {
+		event = k8sutil.MemberRolledBackEvent(memberName, oldVersion, c.cluster.Spec.Version, c.cluster)
+	}
+	if _, err = c.eventsCli.Create(event); err != nil {
+		c.logger.Errorf("failed to create member upgraded event: %v", err)
+	}
+
+	return nil
+}
 
-	c.logger.Infof("upgrading the zookeeper member %v from %s to %s", memberName, k8sutil.GetZookeeperVersion(pod), c.cluster.Spec.Version)
-	pod.Spec.Containers[0].Image = k8sutil.ImageName(c.cluster.Spec.Repository, c.cluster.Spec.Version)
-	k8sutil.SetZookeeperVersion(pod, c.cluster.Spec.Version)
+// upgradePath returns the version changes the upgrade policy allows.
+func upgradePath(u *api.UpgradePolicy) versionutil.UpgradePath {
+	p := versionutil.UpgradePath{MaxMinorSkew: api.DefaultMaxMinorVersionSkew}
+	if u == nil {
+		return p
+	}
+	if u.MaxMinorVersionSkew > 0 {
+		p.MaxMinorSkew = u.MaxMinorVersionSkew
+	}
+	p.AllowMinorDowngrade = u.AllowMinorDowngrade
+	p.AllowMajorChange = u.AllowMajorVersionChange
+	return p
+}
+
+// checkUpgradePath returns an error unless every member may be moved to the given version.
+// Versions of members that do not parse are not checked.
+func checkUpgradePath(pods []*v1.Pod, to string, p versionutil.UpgradePath) error {
+	for _, pod := range pods {
+		from := k8sutil.GetZookeeperVersion(pod)
+		if _, err := versionutil.Parse(from); err != nil || from == to {
+			continue
+		}
+		if err := versionutil.CheckUpgradePath(from, to, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	patchdata, err := k8sutil.CreatePatch(oldpod, pod, v1.Pod{})
+// rejectUpgrade reports that the version of the spec cannot be upgraded to.
+func (c *Cluster) rejectUpgrade(reason error) {
+	c.status.SetUpgradeRejectedCondition(c.cluster.Spec.Version, reason.Error())
+	if c.rejectedVersion == c.cluster.Spec.Version {
+		return
+	}
+	c.rejectedVersion = c.cluster.Spec.Version
+	c.logger.Errorf("rejecting the upgrade to %s: %v", c.cluster.Spec.Version, reason)
+	_, err := c.eventsCli.Create(k8sutil.UpgradeRejectedEvent(c.cluster.Spec.Version, reason.Error(), c.cluster))
 	if err != nil {
-		return fmt.Errorf("error creating patch: %v", err)
+		c.logger.Errorf("failed to create upgrade rejected event: %v", err)
 	}
+}
+
+// pickOneUnreadyMember picks a candidate whose pod is not ready.
+func pickOneUnreadyMember(pods []*v1.Pod, candidates []*zookeeperutil.Member) *zookeeperutil.Member {
+	for _, m := range candidates {
+		for _, pod := range pods {
+			if pod.Name == m.Name && !k8sutil.IsPodReady(pod) {
+				return m
+			}
+		}
+	}
+	return nil
+}
 
-	_, err = c.config.KubeCli.CoreV1().Pods(ns).Patch(pod.GetName(), types.StrategicMergePatchType, patchdata)
+// restartOneMember deletes the pod of the given member. The member is then recreated
+// from the current spec by the dead member replacement of the next reconciliation. A
+// member is only restarted if the ensemble keeps its quorum without it, which rules out
+// ensembles of one or two members.
+func (c *Cluster) restartOneMember(memberName, reason string) error {
+	pod, err := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace).Get(memberName, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("fail to update the zookeeper member (%s): %v", memberName, err)
+		return fmt.Errorf("fail to get pod (%s): %v", memberName, err)
 	}
-	c.logger.Infof("finished upgrading the zookeeper member %v", memberName)
-	_, err = c.eventsCli.Create(k8sutil.MemberUpgradedEvent(memberName, k8sutil.GetZookeeperVersion(oldpod), c.cluster.Spec.Version, c.cluster))
+	if err := c.checkQuorumWithout(memberName); err != nil {
+		c.refuseRestart(memberName, err.Error())
+		return nil
+	}
+	c.rollout = newMemberRollout(pod)
+
+	c.logger.Infof("restarting the zookeeper member %v: %s", memberName, reason)
+	_, err = c.eventsCli.Create(k8sutil.MemberRestartEvent(memberName, reason, c.cluster))
 	if err != nil {
-		c.logger.Errorf("failed to create member upgraded event: %v", err)
+		c.logger.Errorf("failed to create member restart event: %v", err)
 	}
 
+	if err := c.removePod(memberName, true); err != nil {
+		return fmt.Errorf("fail to restart the zookeeper member (%s): %v", memberName, err)
+	}
 	return nil
 }
+
+// refuseRestart reports that the given member cannot be restarted without losing the
+// quorum. The restart is retried on every reconciliation, e.g. until the cluster is
+// scaled up.
+func (c *Cluster) refuseRestart(memberName, msg string) {
+	msg = fmt.Sprintf("member %s: %s", memberName, msg)
+	c.logger.Warningf("not restarting the zookeeper member: %s", msg)
+	if !c.status.SetRestartRefusedCondition(msg) {
+		return
+	}
+	_, err := c.eventsCli.Create(k8sutil.MemberRestartRefusedEvent(memberName, msg, c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create member restart refused event: %v", err)
+	}
+}
+
+// restartNextMember restarts the next of the given members once the last restarted
+// one is back, followers first and the leader last. A member that is down does not
+// serve anyway and is restarted right away.
+func (c *Cluster) restartNextMember(pods []*v1.Pod, ms []*zookeeperutil.Member, reason string, hashes podTemplateHashes) error {
+	if c.isRestartStopped() {
+		return nil
+	}
+	if c.waitForRollout(pods, "restarting the next one", hashes) {
+		if m := pickOneUnreadyMember(pods, ms); c.rollout == nil && len(c.stoppedRollout) == 0 && m != nil {
+			return c.restartOneMember(m.Name, reason)
+		}
+		return nil
+	}
+	m, err := c.pickOneMemberLeaderLast(ms)
+	if err != nil {
+		return err
+	}
+	return c.restartOneMember(m.Name, reason)
+}
+
+// waitForRollout tells whether the last restarted member is not back yet, in which case
+// the next step has to wait. The restart is stopped if the member failed to come back
+// in time or its image cannot be pulled.
+func (c *Cluster) waitForRollout(pods []*v1.Pod, next string, hashes podTemplateHashes) bool {
+	if c.isRolloutDone(pods) {
+		return false
+	}
+	if msg := c.rolloutImagePullError(pods); len(msg) != 0 {
+		c.stopRestart("Member image pull failed", msg, hashes)
+		return true
+	}
+	if c.isRolloutTimedOut() {
+		c.stopRestart("Member restart timed out", fmt.Sprintf("not ready and synced after %v", c.memberUpgradeTimeout()), hashes)
+		return true
+	}
+	c.logger.Infof("waiting for all members to rejoin and sync before %s", next)
+	return true
+}
+
+// isRestartStopped tells whether the rolling restart of the members was stopped.
+func (c *Cluster) isRestartStopped() bool {
+	if len(c.stoppedRollout) == 0 {
+		return false
+	}
+	c.logger.Warningf("restart of the members is stopped, waiting for the pod spec, the image or the member secrets to change")
+	return true
+}
+
+// stopRestart stops the rolling restart because the last restarted member did not come
+// back. No further member is restarted until the rollout target changes.
+func (c *Cluster) stopRestart(reason, msg string, hashes podTemplateHashes) {
+	msg = fmt.Sprintf("member %s: %s", c.rollout.name, msg)
+	c.logger.Errorf("stopping the restart of the members: %s", msg)
+	c.status.SetRestartFailedCondition(reason, msg)
+	_, err := c.eventsCli.Create(k8sutil.MemberRestartFailedEvent(c.rollout.name, msg, c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create member restart failed event: %v", err)
+	}
+	c.stoppedRollout = c.rolloutTarget(hashes)
+	c.rollout = nil
+}
+
+// rolloutTarget identifies what the members are restarted into: the pod templates, the
+// image, the member secrets and the quorum TLS phase.
+func (c *Cluster) rolloutTarget(hashes podTemplateHashes) string {
+	image := k8sutil.ZookeeperImage(c.cluster.Spec)
+	return strings.Join([]string{hashes.participant, hashes.observer, image, c.memberSecretsHash, string(c.status.QuorumTLSPhase), string(c.status.QuorumSASLPhase)}, "/")
+}
+
+// memberRollout remembers which incarnation of a member pod was upgraded or restarted.
+type memberRollout struct {
+	name        string
+	incarnation string
+	start       time.Time
+}
+
+func newMemberRollout(pod *v1.Pod) *memberRollout {
+	return &memberRollout{name: pod.Name, incarnation: podIncarnation(pod), start: time.Now()}
+}
+
+// podIncarnation identifies a pod and the run of its zookeeper container. It changes when
+// the pod is recreated or the container is restarted, e.g. after an image update.
+func podIncarnation(pod *v1.Pod) string {
+	id := string(pod.UID)
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == "zookeeper" && cs.State.Running != nil {
+			id += "/" + cs.State.Running.StartedAt.UTC().String()
+		}
+	}
+	return id
+}
+
+// isRolloutDone tells whether the last upgraded or restarted member runs again and
+// the ensemble is synced, i.e. whether the next member may be rolled.
+func (c *Cluster) isRolloutDone(pods []*v1.Pod) bool {
+	if c.rollout != nil && !c.isRolledMemberBack(pods) {
+		return false
+	}
+	if !c.isEnsembleSynced(pods) {
+		return false
+	}
+	c.rollout = nil
+	return true
+}
+
+// isRolledMemberBack tells whether the rolled member runs a new incarnation that is
+// ready and answers to ruok.
+func (c *Cluster) isRolledMemberBack(pods []*v1.Pod) bool {
+	for _, pod := range pods {
+		if pod.Name != c.rollout.name {
+			continue
+		}
+		if podIncarnation(pod) == c.rollout.incarnation || !k8sutil.IsPodReady(pod) {
+			return false
+		}
+		m := c.newMemberNamed(pod.Name)
+		m.PodIP = pod.Status.PodIP
+		host, tlsConfig, err := c.memberClientHost(m)
+		if err != nil {
+			return false
+		}
+		return zookeeperutil.IsServerOK(host, tlsConfig)
+	}
+	return false
+}
+
+func (c *Cluster) memberUpgradeTimeout() time.Duration {
+	timeout := api.DefaultMemberUpgradeTimeoutInSecond
+	if u := c.cluster.Spec.Upgrade; u != nil && u.MemberTimeoutInSecond > 0 {
+		timeout = u.MemberTimeoutInSecond
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// isRolloutTimedOut tells whether the rolled member failed to come back in time.
+func (c *Cluster) isRolloutTimedOut() bool {
+	return c.rollout != nil && time.Since(c.rollout.start) > c.memberUpgradeTimeout()
+}
+
+// rolloutImagePullError returns why the image of the rolled member cannot be pulled,
+// e.g. because its version does not exist, or an empty string.
+func (c *Cluster) rolloutImagePullError(pods []*v1.Pod) string {
+	if c.rollout == nil {
+		return ""
+	}
+	for _, pod := range pods {
+		if pod.Name != c.rollout.name {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name != "zookeeper" || cs.State.Waiting == nil {
+				continue
+			}
+			switch cs.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+				return fmt.Sprintf("%s: %s", cs.State.Waiting.Reason, cs.State.Waiting.Message)
+			}
+		}
+	}
+	return ""
+}
+
+// stopUpgrade stops the upgrade because the last upgraded member did not come back.
+// No further member is upgraded until the version is changed again.
+func (c *Cluster) stopUpgrade(reason, msg string) {
+	msg = fmt.Sprintf("member %s: %s", c.rollout.name, msg)
+	c.logger.Errorf("stopping the upgrade to %s: %s", c.cluster.Spec.Version, msg)
+	c.status.SetUpgradeFailedCondition(c.cluster.Spec.Version, reason, msg)
+	_, err := c.eventsCli.Create(k8sutil.MemberUpgradeFailedEvent(c.rollout.name, c.cluster.Spec.Version, msg, c.cluster))
+	if err != nil {
+		c.logger.Errorf("failed to create member upgrade failed event: %v", err)
+	}
+	c.rollout = nil
+}
+
+// memberClientHost returns the client address of the member together with the TLS
+// config to connect to it.
+func (c *Cluster) memberClientHost(m *zookeeperutil.Member) (string, *tls.Config, error) {
+	addr, err := c.ResolvePodServiceAddress(m)
+	if err != nil {
+		return "", nil, err
+	}
+	tlsConfig, err := c.clientTLSConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s:%d", addr, c.clientPort()), tlsConfig, nil
+}
+
+// memberStats returns the srvr stats of every member, nil for the members that do not
+// answer to srvr because it is not whitelisted.
+func (c *Cluster) memberStats() (map[string]*zookeeperutil.ServerStats, error) {
+	stats := make(map[string]*zookeeperutil.ServerStats)
+	for _, m := range c.members {
+		host, tlsConfig, err := c.memberClientHost(m)
+		if err != nil {
+			return nil, err
+		}
+		s, err := zookeeperutil.GetServerStats(host, tlsConfig)
+		if err == zookeeperutil.ErrNotWhitelisted {
+			stats[m.Name] = nil
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fail to get stats of member (%s): %v", m.Name, err)
+		}
+		stats[m.Name] = s
+	}
+	return stats, nil
+}
+
+// isEnsembleSynced tells whether all members are ready, serving as part of the quorum
+// and, as far as the leader reports it, in sync with the leader. Members that do not
+// answer to the four letter words are only checked for readiness.
+func (c *Cluster) isEnsembleSynced(pods []*v1.Pod) bool {
+	if !allPodsReady(pods) {
+		return false
+	}
+	stats, err := c.memberStats()
+	if err != nil {
+		c.logger.Infof("ensemble is not synced: %v", err)
+		return false
+	}
+
+	var leader *zookeeperutil.Member
+	followers := 0
+	for name, s := range stats {
+		if s == nil {
+			continue
+		}
+		switch s.Mode {
+		case zookeeperutil.ModeLeader:
+			leader = c.members[name]
+		case zookeeperutil.ModeFollower:
+			followers++
+		case zookeeperutil.ModeObserver:
+		default:
+			c.logger.Infof("ensemble is not synced: member (%s) is in mode %s", name, s.Mode)
+			return false
+		}
+	}
+	if leader == nil {
+		return true
+	}
+
+	host, tlsConfig, err := c.memberClientHost(leader)
+	if err != nil {
+		c.logger.Infof("ensemble is not synced: %v", err)
+		return false
+	}
+	mntr, err := zookeeperutil.GetMonitorStats(host, tlsConfig)
+	if err == zookeeperutil.ErrNotWhitelisted {
+		return true
+	}
+	if err != nil {
+		c.logger.Infof("ensemble is not synced: fail to get monitor stats of the leader (%s): %v", leader.Name, err)
+		return false
+	}
+	synced, err := strconv.Atoi(mntr["zk_synced_followers"])
+	if err != nil || synced < followers {
+		c.logger.Infof("ensemble is not synced: %s of %d followers are synced with the leader (%s)", mntr["zk_synced_followers"], followers, leader.Name)
+		return false
+	}
+	return true
+}
+
+// findLeader returns the name of the current leader, or of the member running standalone.
+// It returns an error if the leader cannot be determined, e.g. because a member does not
+// answer to srvr, rather than let a leader be mistaken for a follower.
+func (c *Cluster) findLeader() (string, error) {
+	stats, err := c.memberStats()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the leader: %v", err)
+	}
+	for name, s := range stats {
+		if s != nil && (s.Mode == zookeeperutil.ModeLeader || s.Mode == zookeeperutil.ModeStandalone) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("failed to find the leader: no member reports to be the leader")
+}
+
+// pickOneMemberLeaderLast picks the candidate with the highest ID that is not the leader.
+// The leader is only picked once it is the last candidate left, which avoids bouncing
+// the leadership across the ensemble while it is rolled.
+func (c *Cluster) pickOneMemberLeaderLast(candidates []*zookeeperutil.Member) (*zookeeperutil.Member, error) {
+	leader, err := c.findLeader()
+	if err != nil {
+		return nil, err
+	}
+	return pickLeaderLast(candidates, leader)
+}
+
+// pickLeaderLast picks the candidate with the highest ID that is not the given leader,
+// or the leader if it is the only candidate. An unknown leader is an error, since any
+// candidate may be the leader then.
+func pickLeaderLast(candidates []*zookeeperutil.Member, leader string) (*zookeeperutil.Member, error) {
+	if len(leader) == 0 {
+		return nil, errors.New("the leader is unknown")
+	}
+	var picked *zookeeperutil.Member
+	for _, m := range candidates {
+		if m.Name == leader {
+			continue
+		}
+		if picked == nil || m.ID() > picked.ID() {
+			picked = m
+		}
+	}
+	if picked == nil && len(candidates) > 0 {
+		picked = candidates[0]
+	}
+	return picked, nil
+}
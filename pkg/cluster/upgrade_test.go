@@ -0,0 +1,241 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newRolloutTestCluster() *Cluster {
+	kubecli := fake.NewSimpleClientset()
+	cl := &api.ZookeeperCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault},
+		Spec:       api.ClusterSpec{Size: 3, Version: "3.5.3-beta"},
+	}
+	return &Cluster{
+		logger:    logrus.WithField("pkg", "cluster"),
+		config:    Config{KubeCli: kubecli},
+		cluster:   cl,
+		eventsCli: kubecli.CoreV1().Events(cl.Namespace),
+	}
+}
+
+// rolledPod returns the pod of a member that was restarted the given time ago and did
+// not come back yet.
+func rolledPod(c *Cluster, name string, since time.Duration) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.cluster.Namespace, UID: "uid-1"}}
+	c.rollout = newMemberRollout(pod)
+	c.rollout.start = time.Now().Add(-since)
+	return pod
+}
+
+func TestWaitForRolloutTimeout(t *testing.T) {
+	c := newRolloutTestCluster()
+	hashes := podTemplateHashes{participant: "p1", observer: "o1"}
+	pods := []*v1.Pod{rolledPod(c, "test-2", time.Minute)}
+
+	if !c.waitForRollout(pods, "restarting the next one", hashes) {
+		t.Fatalf("expected to wait for the restarted member")
+	}
+	if c.rollout == nil || len(c.stoppedRollout) != 0 {
+		t.Fatalf("expected the restart to go on before the timeout")
+	}
+
+	c.rollout.start = time.Now().Add(-c.memberUpgradeTimeout() - time.Second)
+	if !c.waitForRollout(pods, "restarting the next one", hashes) {
+		t.Fatalf("expected to wait once the restart timed out")
+	}
+	if c.rollout != nil || c.stoppedRollout != c.rolloutTarget(hashes) {
+		t.Errorf("expected the restart to stop at %q, got rollout=%v, stopped=%q", c.rolloutTarget(hashes), c.rollout, c.stoppedRollout)
+	}
+	if _, cond := getCondition(c, api.ClusterConditionRestarting); cond == nil || cond.Status != v1.ConditionFalse || cond.Reason != "Member restart timed out" {
+		t.Errorf("unexpected restarting condition: %+v", cond)
+	}
+
+	// Nothing is restarted while stopped, the fake client has no pod to restart.
+	ms := []*zookeeperutil.Member{{Name: "test-1"}}
+	if err := c.restartNextMember(pods, ms, "pod spec changed", hashes); err != nil || c.rollout != nil {
+		t.Errorf("expected no restart while stopped, got err=%v, rollout=%v", err, c.rollout)
+	}
+}
+
+func TestWaitForRolloutImagePullError(t *testing.T) {
+	c := newRolloutTestCluster()
+	hashes := podTemplateHashes{participant: "p1", observer: "o1"}
+	pod := rolledPod(c, "test-2", time.Second)
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:  "zookeeper",
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ErrImagePull"}},
+	}}
+
+	if !c.waitForRollout([]*v1.Pod{pod}, "restarting the next one", hashes) || len(c.stoppedRollout) == 0 {
+		t.Fatalf("expected the restart to stop on an image pull error")
+	}
+	if _, cond := getCondition(c, api.ClusterConditionRestarting); cond == nil || cond.Reason != "Member image pull failed" {
+		t.Errorf("unexpected restarting condition: %+v", cond)
+	}
+}
+
+func TestStopUpgrade(t *testing.T) {
+	c := newRolloutTestCluster()
+	c.status.CurrentVersion = "3.5.2-alpha"
+	c.status.UpgradeVersionTo(c.cluster.Spec.Version)
+	rolledPod(c, "test-2", time.Hour)
+
+	if !c.isRolloutTimedOut() {
+		t.Fatalf("expected the upgrade of the member to time out")
+	}
+	c.stopUpgrade("Member upgrade timed out", "not ready")
+	if c.rollout != nil || !c.status.IsUpgradeFailed(c.cluster.Spec.Version) {
+		t.Errorf("expected the upgrade to %s to be stopped", c.cluster.Spec.Version)
+	}
+	if c.status.IsUpgradeFailed("3.5.4-beta") {
+		t.Errorf("expected another version to be upgraded to again")
+	}
+	if h := c.status.UpgradeHistory; len(h) != 1 || h[0].Outcome != api.UpgradeOutcomeFailed {
+		t.Errorf("unexpected upgrade history: %+v", h)
+	}
+}
+
+func getCondition(c *Cluster, t api.ClusterConditionType) (int, *api.ClusterCondition) {
+	for i, cond := range c.status.Conditions {
+		if cond.Type == t {
+			return i, &cond
+		}
+	}
+	return -1, nil
+}
+
+func TestPickLeaderLast(t *testing.T) {
+	members := func(names ...string) []*zookeeperutil.Member {
+		var ms []*zookeeperutil.Member
+		for _, name := range names {
+			ms = append(ms, &zookeeperutil.Member{Name: name})
+		}
+		return ms
+	}
+	tests := []struct {
+		name       string
+		candidates []*zookeeperutil.Member
+		leader     string
+		wPicked    string
+		wErr       bool
+	}{
+		{name: "highest ID follower", candidates: members("test-1", "test-3", "test-2"), leader: "test-1", wPicked: "test-3"},
+		{name: "leader has the highest ID", candidates: members("test-1", "test-3", "test-2"), leader: "test-3", wPicked: "test-2"},
+		{name: "IDs above 9", candidates: members("test-9", "test-10", "test-2"), leader: "test-2", wPicked: "test-10"},
+		{name: "leader unknown", candidates: members("test-4", "test-5"), leader: "", wErr: true},
+		{name: "leader not a candidate", candidates: members("test-4", "test-5"), leader: "test-1", wPicked: "test-5"},
+		{name: "only the leader left", candidates: members("test-3"), leader: "test-3", wPicked: "test-3"},
+		{name: "no candidate", candidates: nil, leader: "test-1", wPicked: ""},
+	}
+	for _, tt := range tests {
+		m, err := pickLeaderLast(tt.candidates, tt.leader)
+		if (err != nil) != tt.wErr {
+			t.Errorf("%s: err get=%v, want error=%v", tt.name, err, tt.wErr)
+		}
+		var picked string
+		if m != nil {
+			picked = m.Name
+		}
+		if picked != tt.wPicked {
+			t.Errorf("%s: picked get=%q, want=%q", tt.name, picked, tt.wPicked)
+		}
+	}
+}
+
+func TestCheckUpgradePathPolicy(t *testing.T) {
+	pod := func(version string) *v1.Pod {
+		p := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-1", Annotations: map[string]string{}}}
+		k8sutil.SetZookeeperVersion(p, version)
+		return p
+	}
+	tests := []struct {
+		name   string
+		from   string
+		policy *api.UpgradePolicy
+		wErr   bool
+	}{
+		{name: "no policy skips a minor version", from: "3.4.10", policy: nil, wErr: true},
+		{name: "unset skew defaults to one", from: "3.4.10", policy: &api.UpgradePolicy{}, wErr: true},
+		{name: "larger skew", from: "3.4.10", policy: &api.UpgradePolicy{MaxMinorVersionSkew: 2}, wErr: false},
+		{name: "downgrade", from: "3.7.0", policy: &api.UpgradePolicy{MaxMinorVersionSkew: 2}, wErr: true},
+		{name: "allowed downgrade", from: "3.7.0", policy: &api.UpgradePolicy{MaxMinorVersionSkew: 2, AllowMinorDowngrade: true}, wErr: false},
+		{name: "major version change", from: "4.0.0", policy: &api.UpgradePolicy{}, wErr: true},
+		{name: "allowed major version change", from: "4.0.0", policy: &api.UpgradePolicy{AllowMajorVersionChange: true}, wErr: false},
+	}
+	for _, tt := range tests {
+		err := checkUpgradePath([]*v1.Pod{pod(tt.from)}, "3.6.0", upgradePath(tt.policy))
+		if (err != nil) != tt.wErr {
+			t.Errorf("%s: err get=%v, want error=%v", tt.name, err, tt.wErr)
+		}
+	}
+}
+
+func TestRestartOneMemberKeepsQuorum(t *testing.T) {
+	tests := []struct {
+		name     string
+		observer bool
+		wRestart bool
+	}{
+		{name: "only participant", observer: false, wRestart: false},
+		{name: "observer", observer: true, wRestart: true},
+	}
+	for _, tt := range tests {
+		c := newRolloutTestCluster()
+		c.cluster.Spec.Size = 1
+		participant := c.newMemberNamed("test-1")
+		observer := c.newMemberNamed("test-2")
+		observer.Observer = true
+		c.members = zookeeperutil.NewMemberSet(participant, observer)
+		name := participant.Name
+		if tt.observer {
+			name = observer.Name
+		}
+		pods := c.config.KubeCli.CoreV1().Pods(c.cluster.Namespace)
+		if _, err := pods.Create(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.cluster.Namespace}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.restartOneMember(name, "pod spec changed"); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		_, err := pods.Get(name, metav1.GetOptions{})
+		if restarted := err != nil; restarted != tt.wRestart {
+			t.Errorf("%s: restarted get=%v, want=%v", tt.name, restarted, tt.wRestart)
+		}
+		if (c.rollout != nil) != tt.wRestart {
+			t.Errorf("%s: rollout get=%v, want a rollout=%v", tt.name, c.rollout, tt.wRestart)
+		}
+		refused := false
+		for _, cd := range c.status.Conditions {
+			if cd.Type == api.ClusterConditionRestarting && cd.Reason == "Quorum at risk" {
+				refused = true
+			}
+		}
+		if refused == tt.wRestart {
+			t.Errorf("%s: restart refused condition get=%v, want=%v", tt.name, refused, !tt.wRestart)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/backup"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+)
+
+func (c *Controller) runBackupInformer(ns string, stopCh <-chan struct{}) {
+	source := cache.NewListWatchFromClient(
+		c.Config.ZookeeperCRCli.ZookeeperV1alpha1().RESTClient(),
+		api.ZookeeperBackupResourcePlural,
+		ns,
+		fields.Everything())
+
+	_, informer := cache.NewIndexerInformer(source, &api.ZookeeperBackup{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.onAddZookeeperBackup,
+		UpdateFunc: c.onUpdateZookeeperBackup,
+		DeleteFunc: c.onDeleteZookeeperBackup,
+	}, cache.Indexers{})
+
+	informer.Run(stopCh)
+}
+
+func (c *Controller) onAddZookeeperBackup(obj interface{}) {
+	c.startBackup(obj.(*api.ZookeeperBackup))
+}
+
+func (c *Controller) onUpdateZookeeperBackup(oldObj, newObj interface{}) {
+	oldBackup := oldObj.(*api.ZookeeperBackup)
+	newBackup := newObj.(*api.ZookeeperBackup)
+	// The backups update their own status.
+	if reflect.DeepEqual(oldBackup.Spec, newBackup.Spec) {
+		return
+	}
+	c.stopBackup(newBackup)
+	c.startBackup(newBackup)
+}
+
+func (c *Controller) onDeleteZookeeperBackup(obj interface{}) {
+	b, ok := obj.(*api.ZookeeperBackup)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			panic(fmt.Sprintf("unknown object from ZookeeperBackup delete event: %#v", obj))
+		}
+		b, ok = tombstone.Obj.(*api.ZookeeperBackup)
+		if !ok {
+			panic(fmt.Sprintf("Tombstone contained object that is not an ZookeeperBackup: %#v", obj))
+		}
+	}
+	c.stopBackup(b)
+}
+
+func (c *Controller) startBackup(b *api.ZookeeperBackup) {
+	if !c.managedAnnotations(b.Annotations) {
+		return
+	}
+	key := backupKey(b)
+	if _, ok := c.backups[key]; ok {
+		return
+	}
+	if err := b.Spec.Validate(); err != nil {
+		c.logger.Warningf("invalid backup spec (%s): %v", key, err)
+		_, eerr := c.KubeCli.CoreV1().Events(b.Namespace).Create(k8sutil.BackupFailedEvent("invalid spec: "+err.Error(), b))
+		if eerr != nil {
+			c.logger.Errorf("failed to create backup failed event: %v", eerr)
+		}
+		return
+	}
+	c.backups[key] = backup.New(c.makeBackupConfig(), b.DeepCopy())
+}
+
+func (c *Controller) stopBackup(b *api.ZookeeperBackup) {
+	key := backupKey(b)
+	if bk, ok := c.backups[key]; ok {
+		bk.Delete()
+		delete(c.backups, key)
+	}
+}
+
+func (c *Controller) makeBackupConfig() backup.Config {
+	return backup.Config{
+		KubeCli:        c.Config.KubeCli,
+		RestConfig:     c.Config.RestConfig,
+		ZookeeperCRCli: c.Config.ZookeeperCRCli,
+	}
+}
+
+func backupKey(b *api.ZookeeperBackup) string {
+	return b.Namespace + "/" + b.Name
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/backup"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/cluster"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/generated/clientset/versioned"
 	"github.com/nuance-mobility/zookeeper-operator/pkg/util/k8sutil"
@@ -27,6 +28,7 @@ import (
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	kwatch "k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 var initRetryWaitTime = 30 * time.Second
@@ -41,6 +43,8 @@ type Controller struct {
 	Config
 
 	clusters map[string]*cluster.Cluster
+	// backups are keyed by namespace/name.
+	backups map[string]*backup.Backup
 }
 
 type Config struct {
@@ -48,6 +52,7 @@ type Config struct {
 	ClusterWide    bool
 	ServiceAccount string
 	KubeCli        kubernetes.Interface
+	RestConfig     *rest.Config
 	KubeExtCli     apiextensionsclient.Interface
 	ZookeeperCRCli      versioned.Interface
 	CreateCRD      bool
@@ -59,6 +64,7 @@ func New(cfg Config) *Controller {
 
 		Config:   cfg,
 		clusters: make(map[string]*cluster.Cluster),
+		backups:  make(map[string]*backup.Backup),
 	}
 }
 
@@ -82,6 +88,7 @@ func (c *Controller) handleClusterEvent(event *Event) (bool, error) {
 	clus.SetDefaults()
 
 	if err := clus.Spec.Validate(); err != nil {
+		c.rejectCluster(event, err)
 		return false, fmt.Errorf("invalid cluster spec. please fix the following problem with the cluster spec: %v", err)
 	}
 
@@ -117,10 +124,28 @@ func (c *Controller) handleClusterEvent(event *Event) (bool, error) {
 	return false, nil
 }
 
+// rejectCluster surfaces an invalid spec. A running cluster records it in its status and
+// keeps its previous spec, otherwise only an event is emitted.
+func (c *Controller) rejectCluster(event *Event, err error) {
+	clus := event.Object
+	if nc, ok := c.clusters[clus.Name]; ok && event.Type == kwatch.Modified {
+		nc.Reject(clus, err)
+		return
+	}
+	if event.Type == kwatch.Deleted {
+		return
+	}
+	_, eerr := c.KubeCli.CoreV1().Events(clus.Namespace).Create(k8sutil.SpecRejectedEvent(err.Error(), clus))
+	if eerr != nil {
+		c.logger.Errorf("failed to create spec rejected event: %v", eerr)
+	}
+}
+
 func (c *Controller) makeClusterConfig() cluster.Config {
 	return cluster.Config{
 		ServiceAccount: c.Config.ServiceAccount,
 		KubeCli:        c.Config.KubeCli,
+		RestConfig:     c.Config.RestConfig,
 		ZookeeperCRCli:      c.Config.ZookeeperCRCli,
 	}
 }
@@ -130,5 +155,12 @@ func (c *Controller) initCRD() error {
 	if err != nil {
 		return fmt.Errorf("failed to create CRD: %v", err)
 	}
-	return k8sutil.WaitCRDReady(c.KubeExtCli, api.ZookeeperClusterCRDName)
+	if err := k8sutil.WaitCRDReady(c.KubeExtCli, api.ZookeeperClusterCRDName); err != nil {
+		return err
+	}
+	err = k8sutil.CreateCRD(c.KubeExtCli, api.ZookeeperBackupCRDName, api.ZookeeperBackupResourceKind, api.ZookeeperBackupResourcePlural, "zkbackup")
+	if err != nil {
+		return fmt.Errorf("failed to create backup CRD: %v", err)
+	}
+	return k8sutil.WaitCRDReady(c.KubeExtCli, api.ZookeeperBackupCRDName)
 }
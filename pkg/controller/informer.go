@@ -74,6 +74,7 @@ func (c *Controller) run() {
 	}, cache.Indexers{})
 
 	ctx := context.TODO()
+	go c.runBackupInformer(ns, ctx.Done())
 	// TODO: use workqueue to avoid blocking
 	informer.Run(ctx.Done())
 }
@@ -142,7 +143,11 @@ func (c *Controller) syncZookeeperClus(clus *api.ZookeeperCluster) {
 }
 
 func (c *Controller) managed(clus *api.ZookeeperCluster) bool {
-	if v, ok := clus.Annotations[k8sutil.AnnotationScope]; ok {
+	return c.managedAnnotations(clus.Annotations)
+}
+
+func (c *Controller) managedAnnotations(annotations map[string]string) bool {
+	if v, ok := annotations[k8sutil.AnnotationScope]; ok {
 		if c.Config.ClusterWide {
 			return v == k8sutil.AnnotationClusterWide
 		}
@@ -0,0 +1,181 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	saslVolumeName = "member-sasl"
+	saslDir        = "/sasl"
+
+	jaasFile          = "jaas.conf"
+	superDigestKey    = "superDigest"
+	superDigestEnv    = "ZOO_SUPER_DIGEST"
+	digestLoginModule = "org.apache.zookeeper.server.auth.DigestLoginModule"
+
+	// Keys of the super user and quorum secrets.
+	SASLUsernameKey = "username"
+	SASLPasswordKey = "password"
+)
+
+// SASLSecretName is the name of the secret the operator renders the JAAS file of the
+// members into.
+func SASLSecretName(clusterName string) string {
+	return clusterName + "-sasl"
+}
+
+// SASLUsers are the credentials the JAAS file of the members is rendered from.
+type SASLUsers struct {
+	SuperPassword  string
+	Clients        map[string]string
+	QuorumUser     string
+	QuorumPassword string
+}
+
+// RenderJAAS returns the JAAS file of the members. The Server section authenticates the
+// clients, the Quorum sections the members to each other.
+func RenderJAAS(users SASLUsers) string {
+	var b bytes.Buffer
+	b.WriteString("Server {\n")
+	fmt.Fprintf(&b, "    %s required\n", digestLoginModule)
+	fmt.Fprintf(&b, "    user_%s=%s", zookeeperutil.SuperUser, jaasQuote(users.SuperPassword))
+	var names []string
+	for name := range users.Clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n    user_%s=%s", name, jaasQuote(users.Clients[name]))
+	}
+	b.WriteString(";\n};\n")
+
+	if len(users.QuorumUser) != 0 {
+		fmt.Fprintf(&b, "QuorumServer {\n    %s required\n    user_%s=%s;\n};\n",
+			digestLoginModule, users.QuorumUser, jaasQuote(users.QuorumPassword))
+		fmt.Fprintf(&b, "QuorumLearner {\n    %s required\n    username=%s\n    password=%s;\n};\n",
+			digestLoginModule, jaasQuote(users.QuorumUser), jaasQuote(users.QuorumPassword))
+	}
+	return b.String()
+}
+
+func jaasQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+// NewSASLSecret returns the secret holding the JAAS file and the super digest of the members.
+func NewSASLSecret(clusterName, ns string, users SASLUsers, owner metav1.OwnerReference) *v1.Secret {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SASLSecretName(clusterName),
+			Namespace: ns,
+			Labels:    LabelsForCluster(clusterName),
+		},
+		Data: map[string][]byte{
+			jaasFile:       []byte(RenderJAAS(users)),
+			superDigestKey: []byte(zookeeperutil.SuperDigest(users.SuperPassword)),
+		},
+	}
+	addOwnerRefToObject(secret.GetObjectMeta(), owner)
+	return secret
+}
+
+// CreateOrUpdateSecret creates the secret, or updates its data if it exists.
+func CreateOrUpdateSecret(kubecli kubernetes.Interface, secret *v1.Secret) (*v1.Secret, error) {
+	created, err := kubecli.CoreV1().Secrets(secret.Namespace).Create(secret)
+	if err == nil {
+		return created, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	existing, err := kubecli.CoreV1().Secrets(secret.Namespace).Get(secret.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(existing.Data, secret.Data) {
+		return existing, nil
+	}
+	existing.Data = secret.Data
+	return kubecli.CoreV1().Secrets(secret.Namespace).Update(existing)
+}
+
+// saslEnv returns the environment enforcing ACLs and the super digest of the members.
+func saslEnv(clusterName string) []v1.EnvVar {
+	return []v1.EnvVar{
+		{Name: "ZOO_SKIP_ACL", Value: "false"},
+		secretKeyEnv(superDigestEnv, SASLSecretName(clusterName), superDigestKey),
+	}
+}
+
+// saslProps returns the system properties enabling SASL authentication.
+func saslProps() []string {
+	return []string{
+		"-Dzookeeper.authProvider.1=org.apache.zookeeper.server.auth.SASLAuthenticationProvider",
+		fmt.Sprintf("-Djava.security.auth.login.config=%s/%s", saslDir, jaasFile),
+		fmt.Sprintf("-Dzookeeper.DigestAuthenticationProvider.superDigest=$(%s)", superDigestEnv),
+	}
+}
+
+// quorumSASLConfig returns the zoo.cfg settings of the quorum authentication in the given
+// quorum SASL phase.
+func quorumSASLConfig(ap *api.AuthPolicy, phase api.QuorumSASLPhase) []string {
+	if !ap.IsQuorumSASLEnabled() {
+		return nil
+	}
+	return []string{
+		"quorum.auth.enableSasl=true",
+		"quorum.auth.learnerRequireSasl=" + strconv.FormatBool(phase != api.QuorumSASLPhaseNone),
+		"quorum.auth.serverRequireSasl=" + strconv.FormatBool(phase == api.QuorumSASLPhaseRequired),
+		"quorum.auth.learner.loginContext=QuorumLearner",
+		"quorum.auth.server.loginContext=QuorumServer",
+	}
+}
+
+// addSASLToPod mounts the rendered JAAS file into the zookeeper container.
+func addSASLToPod(pod *v1.Pod, clusterName string) {
+	pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+		Name: saslVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: SASLSecretName(clusterName)},
+		},
+	})
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != "zookeeper" {
+			continue
+		}
+		c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{
+			Name:      saslVolumeName,
+			MountPath: saslDir,
+			ReadOnly:  true,
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"strings"
+	"testing"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+)
+
+func TestRenderJAAS(t *testing.T) {
+	jaas := RenderJAAS(SASLUsers{
+		SuperPassword:  `se"cret`,
+		Clients:        map[string]string{"bob": "b", "alice": "a"},
+		QuorumUser:     "quorum",
+		QuorumPassword: "q",
+	})
+	want := `Server {
+    org.apache.zookeeper.server.auth.DigestLoginModule required
+    user_super="se\"cret"
+    user_alice="a"
+    user_bob="b";
+};
+QuorumServer {
+    org.apache.zookeeper.server.auth.DigestLoginModule required
+    user_quorum="q";
+};
+QuorumLearner {
+    org.apache.zookeeper.server.auth.DigestLoginModule required
+    username="quorum"
+    password="q";
+};
+`
+	if jaas != want {
+		t.Errorf("jaas get=\n%s\nwant=\n%s", jaas, want)
+	}
+}
+
+func TestQuorumSASLConfig(t *testing.T) {
+	tests := []struct {
+		phase    api.QuorumSASLPhase
+		wLearner string
+		wServer  string
+	}{{
+		phase:    api.QuorumSASLPhaseNone,
+		wLearner: "false",
+		wServer:  "false",
+	}, {
+		phase:    api.QuorumSASLPhaseLearnerRequired,
+		wLearner: "true",
+		wServer:  "false",
+	}, {
+		phase:    api.QuorumSASLPhaseRequired,
+		wLearner: "true",
+		wServer:  "true",
+	}}
+	ap := &api.AuthPolicy{SASL: &api.SASLPolicy{SuperUserSecret: "super", QuorumSecret: "quorum", RequireQuorumSASL: true}}
+	for _, tt := range tests {
+		cfg := strings.Join(quorumSASLConfig(ap, tt.phase), " ")
+		for _, w := range []string{"quorum.auth.learnerRequireSasl=" + tt.wLearner, "quorum.auth.serverRequireSasl=" + tt.wServer} {
+			if !strings.Contains(cfg, w) {
+				t.Errorf("%q: config %q does not contain %q", tt.phase, cfg, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/constants"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupStoreContainer is the container of the backup store pod the persistent
+	// volume of the backups is mounted into, at BackupStoreDir.
+	BackupStoreContainer = "backup-store"
+	BackupStoreDir       = "/backup"
+
+	backupStoreVolumeName = "backup"
+	backupStoreApp        = "zookeeper-backup-store"
+
+	// RestoreContainer is the init container of the seed member the backup of a restored
+	// cluster is extracted into.
+	RestoreContainer = "restore"
+
+	// restoredFile tells the restore container that the backup has been extracted.
+	restoredFile = zookeeperDataVolumeMountDir + "/.restored"
+)
+
+// RestoreCommand returns the command extracting the backup archive read from stdin into
+// the data and transaction log directories of the restore container.
+func RestoreCommand() []string {
+	script := fmt.Sprintf(`set -e
+data=%[1]s
+logs=${ZOO_DATA_LOG_DIR:-%[2]s}
+dir="$data/.restore"
+rm -rf "$dir" && mkdir -p "$dir" "$data/version-2" "$logs/version-2"
+tar xzf - -C "$dir"
+if [ -d "$dir/data/version-2" ]; then mv "$dir"/data/version-2/* "$data/version-2/"; fi
+if [ -d "$dir/datalog/version-2" ]; then mv "$dir"/datalog/version-2/* "$logs/version-2/"; fi
+rm -rf "$dir"
+touch %[3]s`, zookeeperDataVolumeMountDir, zookeeperTlogVolumeMountDir, restoredFile)
+	return []string{"sh", "-c", script}
+}
+
+// AddRestoreToPod makes the pod wait for a backup to be extracted into its volumes before
+// zookeeper starts. It must be called once the volumes of the pod are set.
+func AddRestoreToPod(pod *v1.Pod, cs api.ClusterSpec) {
+	c := v1.Container{
+		Name:  RestoreContainer,
+		Image: imageNameBusybox(cs.Pod),
+		// The operator execs RestoreCommand in the container. The grace period lets
+		// the exec return before the container exits.
+		Command: []string{"sh", "-c", fmt.Sprintf(`
+			until [ -f %[1]s ]
+			do
+				sleep 2
+			done
+			sleep 5
+			rm -f %[1]s`, restoredFile)},
+	}
+	for _, zc := range pod.Spec.Containers {
+		if zc.Name != "zookeeper" {
+			continue
+		}
+		c.VolumeMounts = append([]v1.VolumeMount(nil), zc.VolumeMounts...)
+		for _, env := range zc.Env {
+			if env.Name == "ZOO_DATA_LOG_DIR" {
+				c.Env = append(c.Env, env)
+			}
+		}
+	}
+	// The backup is extracted before the DNS check, which waits for the services the
+	// operator creates once the seed member is up.
+	pod.Spec.InitContainers = append([]v1.Container{c}, pod.Spec.InitContainers...)
+}
+
+// BackupStorePath returns the path in the backup store container of the given path of the
+// backup volume. It fails for an absolute path and for one with a ".." element, so that
+// it cannot point outside of the volume.
+func BackupStorePath(p string) (string, error) {
+	if path.IsAbs(p) {
+		return "", fmt.Errorf("invalid backup path (%s): must be relative to the volume", p)
+	}
+	for _, e := range strings.Split(p, "/") {
+		if e == ".." {
+			return "", fmt.Errorf("invalid backup path (%s): must not contain \"..\"", p)
+		}
+	}
+	return path.Join(BackupStoreDir, p), nil
+}
+
+// BackupStorePodName returns the name of the pod holding the persistent volume of the backup.
+func BackupStorePodName(backupName string) string {
+	return backupName + "-backup-store"
+}
+
+// RestoreStorePodName returns the name of the pod holding the persistent volume the
+// cluster is restored from.
+func RestoreStorePodName(clusterName string) string {
+	return clusterName + "-restore-store"
+}
+
+// BackupStoreListOpt selects the backup store pods and the restore store pods.
+func BackupStoreListOpt() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: "app=" + backupStoreApp}
+}
+
+// BackupStoreClaim returns the persistent volume claim the store pod mounts.
+func BackupStoreClaim(pod *v1.Pod) string {
+	vs := pod.Spec.Volumes
+	if len(vs) == 0 || vs[0].PersistentVolumeClaim == nil {
+		return ""
+	}
+	return vs[0].PersistentVolumeClaim.ClaimName
+}
+
+// NewBackupStorePod returns a pod mounting the persistent volume claim the backups are
+// stored on. It idles so that the operator can exec into it.
+func NewBackupStorePod(podName, claimName string, owner metav1.OwnerReference) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: podName,
+			Labels: map[string]string{
+				"app": backupStoreApp,
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:    BackupStoreContainer,
+				Image:   defaultBusyboxImage,
+				Command: []string{"sh", "-c", "trap 'exit 0' TERM; while true; do sleep 3600 & wait; done"},
+				VolumeMounts: []v1.VolumeMount{{
+					Name:      backupStoreVolumeName,
+					MountPath: BackupStoreDir,
+				}},
+			}},
+			Volumes: []v1.Volume{{
+				Name: backupStoreVolumeName,
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+				},
+			}},
+		},
+	}
+	addOwnerRefToObject(pod.GetObjectMeta(), owner)
+	return pod
+}
+
+func BackupSucceededEvent(b *api.ZookeeperBackup) *v1.Event {
+	event := newBackupEvent(b)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Backup Succeeded"
+	event.Message = fmt.Sprintf("Backup of cluster %s from member %s stored at %s (%d bytes, zxid 0x%s)",
+		b.Spec.ClusterName, b.Status.Member, b.Status.Path, b.Status.Size, b.Status.Zxid)
+	return event
+}
+
+func BackupFailedEvent(reason string, b *api.ZookeeperBackup) *v1.Event {
+	event := newBackupEvent(b)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Backup Failed"
+	event.Message = fmt.Sprintf("Backup of cluster %s failed: %s", b.Spec.ClusterName, reason)
+	return event
+}
+
+func newBackupEvent(b *api.ZookeeperBackup) *v1.Event {
+	t := time.Now()
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: b.Name + "-",
+			Namespace:    b.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion:      api.SchemeGroupVersion.String(),
+			Kind:            api.ZookeeperBackupResourceKind,
+			Name:            b.Name,
+			Namespace:       b.Namespace,
+			UID:             b.UID,
+			ResourceVersion: b.ResourceVersion,
+		},
+		Source: v1.EventSource{
+			Component: os.Getenv(constants.EnvOperatorPodName),
+		},
+		FirstTimestamp: metav1.Time{Time: t},
+		LastTimestamp:  metav1.Time{Time: t},
+		Count:          int32(1),
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"testing"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddRestoreToPod(t *testing.T) {
+	m := &zookeeperutil.Member{Name: "example-1", Namespace: "default"}
+	cs := api.ClusterSpec{Version: "3.5.3-beta"}
+	pod, err := NewZookeeperPod(m, nil, "example", "seed", cs, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone, metav1.OwnerReference{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataPVC := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: PVCNameFromMember(m.Name)}}
+	AddZookeeperVolumeToPod(pod, dataPVC, nil)
+	AddRestoreToPod(pod, cs)
+
+	if len(pod.Spec.InitContainers) != 2 {
+		t.Fatalf("expected 2 init containers, got %d", len(pod.Spec.InitContainers))
+	}
+	c := pod.Spec.InitContainers[0]
+	if c.Name != RestoreContainer {
+		t.Fatalf("expected the restore container first, got %s", c.Name)
+	}
+	zc := pod.Spec.Containers[0]
+	if len(c.VolumeMounts) != len(zc.VolumeMounts) || c.VolumeMounts[0].MountPath != zookeeperDataVolumeMountDir {
+		t.Errorf("expected the volume mounts of zookeeper, got %v", c.VolumeMounts)
+	}
+	if len(c.Env) != 1 || c.Env[0].Name != "ZOO_DATA_LOG_DIR" || c.Env[0].Value != zookeeperDataVolumeMountDir+zookeeperTlogVolumeMountDir {
+		t.Errorf("expected the transaction log dir of zookeeper, got %v", c.Env)
+	}
+}
+
+func TestBackupStorePath(t *testing.T) {
+	tests := []struct {
+		p    string
+		want string
+		wErr bool
+	}{
+		{p: "example_20180102T150405Z_100000002.tar.gz", want: "/backup/example_20180102T150405Z_100000002.tar.gz"},
+		{p: "daily/example_20180102T150405Z_100000002.tar.gz", want: "/backup/daily/example_20180102T150405Z_100000002.tar.gz"},
+		{p: "", want: "/backup"},
+		{p: "../etc/passwd", wErr: true},
+		{p: "daily/../../etc", wErr: true},
+		{p: "/etc/passwd", wErr: true},
+	}
+	for _, tt := range tests {
+		get, err := BackupStorePath(tt.p)
+		if (err != nil) != tt.wErr {
+			t.Errorf("%q: error get=%v, want error=%v", tt.p, err, tt.wErr)
+			continue
+		}
+		if get != tt.want {
+			t.Errorf("%q: path get=%q, want=%q", tt.p, get, tt.want)
+		}
+	}
+}
@@ -58,6 +58,138 @@ func MemberUpgradedEvent(memberName, oldVersion, newVersion string, cl *api.Zook
 	return event
 }
 
+func MemberRolledBackEvent(memberName, oldVersion, newVersion string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Member Rolled Back"
+	event.Message = fmt.Sprintf("Member %s rolled back from %s to %s", memberName, oldVersion, newVersion)
+	return event
+}
+
+func UpgradeRejectedEvent(newVersion, reason string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Upgrade Rejected"
+	event.Message = fmt.Sprintf("Upgrade to %s rejected: %s", newVersion, reason)
+	return event
+}
+
+func SpecRejectedEvent(reason string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Spec Rejected"
+	event.Message = fmt.Sprintf("Invalid spec, the cluster keeps its previous spec: %s", reason)
+	return event
+}
+
+func MemberUpgradeFailedEvent(memberName, newVersion, reason string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Member Upgrade Failed"
+	event.Message = fmt.Sprintf("Upgrade of member %s to %s failed: %s", memberName, newVersion, reason)
+	return event
+}
+
+func MemberRestartEvent(memberName, reason string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Restarting Member"
+	event.Message = fmt.Sprintf("Member %s is being restarted: %s", memberName, reason)
+	return event
+}
+
+func MemberRestartFailedEvent(memberName, reason string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Member Restart Failed"
+	event.Message = fmt.Sprintf("Restart of member %s failed: %s", memberName, reason)
+	return event
+}
+
+func MemberRestartRefusedEvent(memberName, reason string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Member Restart Refused"
+	event.Message = fmt.Sprintf("Member %s is not restarted: %s", memberName, reason)
+	return event
+}
+
+func QuorumTLSPhaseEvent(phase api.QuorumTLSPhase, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Migrating Quorum To TLS"
+	event.Message = fmt.Sprintf("Members are being restarted into quorum TLS phase %s", phase)
+	return event
+}
+
+func QuorumSASLPhaseEvent(phase api.QuorumSASLPhase, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Changing Quorum SASL Requirement"
+	if phase == api.QuorumSASLPhaseNone {
+		event.Message = "Members are being restarted without requiring quorum SASL"
+	} else {
+		event.Message = fmt.Sprintf("Members are being restarted into quorum SASL phase %s", phase)
+	}
+	return event
+}
+
+func CertificateExpiringEvent(secret string, notAfter time.Time, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Certificate Expiring"
+	event.Message = fmt.Sprintf("Certificate of secret %s expires at %s", secret, notAfter.Format(time.RFC3339))
+	return event
+}
+
+func MemberSecretsChangedEvent(cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Member Secrets Changed"
+	event.Message = "TLS or SASL secrets changed, members are being restarted one at a time"
+	return event
+}
+
+func MemberRestoredEvent(memberName string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Member Restored"
+	event.Message = fmt.Sprintf("Seed member %s restored from backup", memberName)
+	return event
+}
+
+func QuorumLostEvent(running, size int, msg string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Quorum Lost"
+	event.Message = fmt.Sprintf("Only %d of %d members are running. %s", running, size, msg)
+	return event
+}
+
+func QuorumRecoveredEvent(cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Quorum Recovered"
+	event.Message = "A majority of the members is running again"
+	return event
+}
+
+func ClusterRebuildEvent(backupPath string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeWarning
+	event.Reason = "Rebuilding Cluster"
+	event.Message = fmt.Sprintf("All members are replaced by a seed member restored from backup %s", backupPath)
+	return event
+}
+
+func MemberMigrationEvent(memberName, nodeName, reason string, cl *api.ZookeeperCluster) *v1.Event {
+	event := newClusterEvent(cl)
+	event.Type = v1.EventTypeNormal
+	event.Reason = "Migrating Member"
+	event.Message = fmt.Sprintf("Member %s is being moved off node %s: %s", memberName, nodeName, reason)
+	return event
+}
+
 func newClusterEvent(cl *api.ZookeeperCluster) *v1.Event {
 	t := time.Now()
 	return &v1.Event{
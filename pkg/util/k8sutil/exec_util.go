@@ -0,0 +1,63 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ExecInContainer runs the command in a container of the pod. The stdin, if not nil,
+// is streamed to the command and its output is written to stdout.
+func ExecInContainer(restcfg *rest.Config, kubecli kubernetes.Interface, ns, podName, container string, cmd []string, stdin io.Reader, stdout io.Writer) error {
+	req := kubecli.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(ns).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: container,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	// The request timeout of the client would cut long running streams.
+	cfg := *restcfg
+	cfg.Timeout = 0
+	exec, err := remotecommand.NewSPDYExecutor(&cfg, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to exec in pod (%s): %v", podName, err)
+	}
+	var stderr bytes.Buffer
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to exec %q in pod (%s): %v: %s", strings.Join(cmd, " "), podName, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
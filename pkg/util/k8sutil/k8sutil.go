@@ -17,6 +17,7 @@ package k8sutil
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"net"
 	"os"
 	"strings"
@@ -43,17 +44,25 @@ import (
 
 const (
 	// ZookeeperClientPort is the client port on client service and zookeeper nodes.
-	ZookeeperClientPort = 2181
+	ZookeeperClientPort = api.MemberClientPort
+	// ZookeeperSecureClientPort is the TLS client port on client service and zookeeper nodes.
+	ZookeeperSecureClientPort = api.SecureClientPort
+
+	// zookeeperRoleLabel is the label of the role of the member a pod or PVC belongs to.
+	zookeeperRoleLabel = "zookeeper_role"
 
 	zookeeperDataVolumeMountDir = "/data"
 	zookeeperTlogVolumeMountDir = "/datalog"
 	zookeeperVersionAnnotationKey = "zookeeper.version"
+	zookeeperPodTemplateHashAnnotationKey = "zookeeper.podtemplate.hash"
+	zookeeperMemberSecretsHashAnnotationKey = "zookeeper.member.secrets.hash"
 
 	randomSuffixLength = 10
 	// k8s object name has a maximum length
 	maxNameLength = 63 - randomSuffixLength - 1
 
 	defaultBusyboxImage = "busybox:1.28.0-glibc"
+	defaultImageTagFormat = "v" + api.ImageTagFormatVersion
 
 	defaultKubeAPIRequestTimeout = 30 * time.Second
 
@@ -73,6 +82,181 @@ func SetZookeeperVersion(pod *v1.Pod, version string) {
 	pod.Annotations[zookeeperVersionAnnotationKey] = version
 }
 
+// GetZookeeperPodTemplateHash returns the hash of the pod template the pod was created from.
+func GetZookeeperPodTemplateHash(pod *v1.Pod) string {
+	return pod.Annotations[zookeeperPodTemplateHashAnnotationKey]
+}
+
+func setZookeeperPodTemplateHash(pod *v1.Pod, hash string) {
+	pod.Annotations[zookeeperPodTemplateHashAnnotationKey] = hash
+}
+
+// GetMemberSecretsHash returns the hash of the TLS and SASL secrets the pod was created with.
+func GetMemberSecretsHash(pod *v1.Pod) string {
+	return pod.Annotations[zookeeperMemberSecretsHashAnnotationKey]
+}
+
+func SetMemberSecretsHash(pod *v1.Pod, hash string) {
+	pod.Annotations[zookeeperMemberSecretsHashAnnotationKey] = hash
+}
+
+// podTemplateHashVersion versions the input of PodTemplateHash. Changing the input on
+// purpose, e.g. hashing another field, restarts every member once and must bump it.
+const podTemplateHashVersion = 1
+
+// podTemplate is the input of PodTemplateHash. The fields of the pod policy are listed
+// one by one, and the Kubernetes types among them are hashed in their canonical form
+// (see canonicalJSON), so that fields a newer client library adds to these types do not
+// change the hash of existing clusters and restart all their members.
+type podTemplate struct {
+	Version          int                  `json:"version"`
+	Repository       string               `json:"repository"`
+	Labels           map[string]string    `json:"labels,omitempty"`
+	Annotations      map[string]string    `json:"annotations,omitempty"`
+	NodeSelector     map[string]string    `json:"nodeSelector,omitempty"`
+	Affinity         interface{}          `json:"affinity,omitempty"`
+	AntiAffinity     bool                 `json:"antiAffinity,omitempty"`
+	Resources        interface{}          `json:"resources,omitempty"`
+	Tolerations      interface{}          `json:"tolerations,omitempty"`
+	ZookeeperEnv     interface{}          `json:"zookeeperEnv,omitempty"`
+	BusyboxImage     string               `json:"busyboxImage,omitempty"`
+	ImagePullSecrets []string             `json:"imagePullSecrets,omitempty"`
+	ImagePullPolicy  string               `json:"imagePullPolicy,omitempty"`
+	DataPVC          bool                 `json:"dataPVC,omitempty"`
+	TlogPVC          bool                 `json:"tlogPVC,omitempty"`
+	Config           *api.ZookeeperConfig `json:"config,omitempty"`
+	JVM              *api.JVMPolicy       `json:"jvm,omitempty"`
+	TLS              *api.TLSPolicy       `json:"tls,omitempty"`
+	QuorumTLS        api.QuorumTLSPhase   `json:"quorumTLS,omitempty"`
+	Auth             *api.AuthPolicy      `json:"auth,omitempty"`
+	QuorumSASL       api.QuorumSASLPhase  `json:"quorumSASL,omitempty"`
+}
+
+// PodTemplateHash returns a hash of the parts of the cluster spec the zookeeper pods
+// are created from, and of the quorum TLS and SASL phases of the members. The version is not
+// part of it as it is rolled out by upgrading the members in place. Of the PVC
+// templates only their presence changes the pods, their specs only apply to new PVCs.
+func PodTemplateHash(cs api.ClusterSpec, quorumTLS api.QuorumTLSPhase, quorumSASL api.QuorumSASLPhase) (string, error) {
+	template := podTemplate{
+		Version:    podTemplateHashVersion,
+		Repository: cs.Repository,
+		Config:     cs.Config,
+		JVM:        cs.JVM,
+		TLS:        podTLSPolicy(cs.TLS, quorumTLS),
+		QuorumTLS:  quorumTLS,
+		Auth:       podAuthPolicy(cs.Auth),
+		QuorumSASL: quorumSASL,
+	}
+	if p := cs.Pod; p != nil {
+		template.Labels = p.Labels
+		template.Annotations = p.Annotations
+		template.NodeSelector = p.NodeSelector
+		template.AntiAffinity = p.AntiAffinity
+		template.BusyboxImage = p.BusyboxImage
+		template.ImagePullPolicy = string(p.ImagePullPolicy)
+		for _, s := range p.ImagePullSecrets {
+			template.ImagePullSecrets = append(template.ImagePullSecrets, s.Name)
+		}
+		template.DataPVC = p.PersistentVolumeClaimSpec != nil
+		template.TlogPVC = p.TlogPersistentVolumeClaimSpec != nil
+
+		var err error
+		if template.Affinity, err = canonicalJSON(p.Affinity); err != nil {
+			return "", err
+		}
+		if template.Resources, err = canonicalJSON(p.Resources); err != nil {
+			return "", err
+		}
+		if template.Tolerations, err = canonicalJSON(p.Tolerations); err != nil {
+			return "", err
+		}
+		if template.ZookeeperEnv, err = canonicalJSON(p.ZookeeperEnv); err != nil {
+			return "", err
+		}
+	}
+	b, err := json.Marshal(template)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pod template: %v", err)
+	}
+	h := fnv.New32a()
+	h.Write(b)
+	return fmt.Sprintf("%08x", h.Sum32()), nil
+}
+
+// canonicalJSON returns the JSON form of v without the null values, empty strings, empty
+// objects and empty arrays, which Kubernetes treats like unset fields. Fields added to a
+// Kubernetes type are unset in existing specs and leave the canonical form as it is. The
+// canonical form of a value without any set field is nil.
+func canonicalJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pod template: %v", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pod template: %v", err)
+	}
+	return pruneUnset(doc), nil
+}
+
+// pruneUnset removes the unset values from the given JSON document, see canonicalJSON.
+func pruneUnset(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		for k, v := range d {
+			if v = pruneUnset(v); v == nil {
+				delete(d, k)
+			} else {
+				d[k] = v
+			}
+		}
+		if len(d) == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(d) == 0 {
+			return nil
+		}
+		for i, v := range d {
+			d[i] = pruneUnset(v)
+		}
+	case string:
+		if len(d) == 0 {
+			return nil
+		}
+	}
+	return doc
+}
+
+// podAuthPolicy returns the part of the auth policy the pods are created from. Whether
+// quorum SASL is required only reaches the pods through the quorum SASL phase.
+func podAuthPolicy(ap *api.AuthPolicy) *api.AuthPolicy {
+	if !ap.IsSASLEnabled() {
+		return ap
+	}
+	sasl := *ap.SASL
+	sasl.RequireQuorumSASL = false
+	return &api.AuthPolicy{SASL: &sasl}
+}
+
+// podTLSPolicy returns the part of the TLS policy the pods are created from, or nil if
+// it does not touch the pods. The peer secret is left out until the migration of the
+// quorum to TLS starts.
+func podTLSPolicy(tp *api.TLSPolicy, quorumTLS api.QuorumTLSPhase) *api.TLSPolicy {
+	if !tp.IsSecureClient() && (!tp.IsSecurePeer() || quorumTLS == api.QuorumTLSPhaseNone) {
+		return nil
+	}
+	st := &api.StaticTLS{
+		Member:                 &api.MemberSecret{ServerSecret: tp.Static.Member.ServerSecret},
+		DisablePlainClientPort: tp.Static.DisablePlainClientPort,
+	}
+	if quorumTLS != api.QuorumTLSPhaseNone {
+		st.Member.PeerSecret = tp.Static.Member.PeerSecret
+		st.ReloadPeerCerts = tp.Static.ReloadPeerCerts
+	}
+	return &api.TLSPolicy{Static: st}
+}
+
 func GetPodNames(pods []*v1.Pod) []string {
 	if len(pods) == 0 {
 		return nil
@@ -89,8 +273,32 @@ func PVCNameFromMember(memberName string) string {
 	return memberName
 }
 
-func ImageName(repo, version string) string {
-	return fmt.Sprintf("%s:v%v", repo, version)
+// TlogPVCNameFromMember the way we get the transaction log PVC name from the member name
+func TlogPVCNameFromMember(memberName string) string {
+	return memberName + "-tlog"
+}
+
+// ZookeeperImage returns the image of the zookeeper container: the image of the spec if set,
+// otherwise the repository tagged with the version in the tag format of the spec.
+func ZookeeperImage(cs api.ClusterSpec) string {
+	if len(cs.Image) != 0 {
+		return cs.Image
+	}
+	format := cs.ImageTagFormat
+	if len(format) == 0 {
+		format = defaultImageTagFormat
+	}
+	return fmt.Sprintf("%s:%s", cs.Repository, strings.Replace(format, api.ImageTagFormatVersion, cs.Version, -1))
+}
+
+// GetZookeeperImage returns the image of the zookeeper container of the pod.
+func GetZookeeperImage(pod *v1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "zookeeper" {
+			return c.Image
+		}
+	}
+	return ""
 }
 
 // imageNameBusybox returns the default image for busybox init container, or the image specified in the PodPolicy
@@ -106,21 +314,57 @@ func PodWithNodeSelector(p *v1.Pod, ns map[string]string) *v1.Pod {
 	return p
 }
 
-func CreateClientService(kubecli kubernetes.Interface, clusterName, ns string, owner metav1.OwnerReference) error {
-	ports := []v1.ServicePort{{
-		Name:       "client",
-		Port:       ZookeeperClientPort,
-		TargetPort: intstr.FromInt(ZookeeperClientPort),
-		Protocol:   v1.ProtocolTCP,
-	}}
-	return createService(kubecli, ClientServiceName(clusterName), clusterName, ns, "", ports, owner)
+// ReconcileClientService creates the client service of the cluster, or updates it to
+// the TLS and service policies if it exists.
+func ReconcileClientService(kubecli kubernetes.Interface, clusterName, ns string, cs api.ClusterSpec, owner metav1.OwnerReference) error {
+	svc := newZookeeperServiceManifest(ClientServiceName(clusterName), clusterName, "", clientServicePorts(cs.TLS, cs.Service))
+	svc.Spec.Type = v1.ServiceTypeClusterIP
+	if sp := cs.Service; sp != nil {
+		if sp.Type != "" {
+			svc.Spec.Type = sp.Type
+		}
+		mergeLabels(svc.Labels, sp.Labels)
+		for k, v := range sp.Annotations {
+			svc.Annotations[k] = v
+		}
+		svc.Spec.LoadBalancerSourceRanges = sp.LoadBalancerSourceRanges
+	}
+	return reconcileService(kubecli, ns, svc, owner)
+}
+
+// clientServicePorts returns the client ports the TLS policy enables. The plaintext
+// client port of the service is taken from the service policy, if any.
+func clientServicePorts(tp *api.TLSPolicy, sp *api.ServicePolicy) []v1.ServicePort {
+	clientPort := serviceClientPort(sp)
+	var ports []v1.ServicePort
+	if !tp.IsPlainClientPortDisabled() {
+		ports = append(ports, v1.ServicePort{
+			Name:       "client",
+			Port:       clientPort,
+			TargetPort: intstr.FromInt(ZookeeperClientPort),
+			Protocol:   v1.ProtocolTCP,
+		})
+	}
+	if tp.IsSecureClient() {
+		ports = append(ports, v1.ServicePort{
+			Name:       "secure-client",
+			Port:       ZookeeperSecureClientPort,
+			TargetPort: intstr.FromInt(ZookeeperSecureClientPort),
+			Protocol:   v1.ProtocolTCP,
+		})
+	}
+	return ports
 }
 
 func ClientServiceName(clusterName string) string {
 	return clusterName + "-client"
 }
 
-func CreatePeerService(kubecli kubernetes.Interface, clusterName, ns string, owner metav1.OwnerReference) error {
+// ReconcilePeerService creates the headless service of the members, or resets its ports
+// to the ones the members listen on if it exists. The quorum and leader election ports
+// are taken from the service policy, if any.
+func ReconcilePeerService(kubecli kubernetes.Interface, clusterName, ns string, sp *api.ServicePolicy, owner metav1.OwnerReference) error {
+	peerPort, leaderPort := sp.MemberPeerPort(), sp.MemberLeaderPort()
 	ports := []v1.ServicePort{{
 		Name:       "client",
 		Port:       ZookeeperClientPort,
@@ -128,27 +372,37 @@ func CreatePeerService(kubecli kubernetes.Interface, clusterName, ns string, own
 		Protocol:   v1.ProtocolTCP,
 	}, {
 		Name:       "peer",
-		Port:       2888,
-		TargetPort: intstr.FromInt(2888),
+		Port:       int32(peerPort),
+		TargetPort: intstr.FromInt(peerPort),
 		Protocol:   v1.ProtocolTCP,
 	}, {
 		Name:       "leader",
-		Port:       3888,
-		TargetPort: intstr.FromInt(3888),
+		Port:       int32(leaderPort),
+		TargetPort: intstr.FromInt(leaderPort),
 		Protocol:   v1.ProtocolTCP,
 	}}
 
-	return createService(kubecli, clusterName, clusterName, ns, v1.ClusterIPNone, ports, owner)
+	svc := newZookeeperServiceManifest(clusterName, clusterName, v1.ClusterIPNone, ports)
+	svc.Spec.Type = v1.ServiceTypeClusterIP
+	return reconcileService(kubecli, ns, svc, owner)
 }
 
-func createService(kubecli kubernetes.Interface, svcName, clusterName, ns, clusterIP string, ports []v1.ServicePort, owner metav1.OwnerReference) error {
-	svc := newZookeeperServiceManifest(svcName, clusterName, clusterIP, ports)
-	addOwnerRefToObject(svc.GetObjectMeta(), owner)
-	_, err := kubecli.CoreV1().Services(ns).Create(svc)
-	if err != nil && !apierrors.IsAlreadyExists(err) {
+// reconcileService creates the given service, or merges it into the existing one.
+func reconcileService(kubecli kubernetes.Interface, ns string, want *v1.Service, owner metav1.OwnerReference) error {
+	svc, err := kubecli.CoreV1().Services(ns).Get(want.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		addOwnerRefToObject(want.GetObjectMeta(), owner)
+		_, err = kubecli.CoreV1().Services(ns).Create(want)
 		return err
 	}
-	return nil
+	if !mergeService(svc, want) {
+		return nil
+	}
+	_, err = kubecli.CoreV1().Services(ns).Update(svc)
+	return err
 }
 
 // CreateAndWaitPod creates a pod and waits until it is running
@@ -186,27 +440,37 @@ func CreateAndWaitPod(kubecli kubernetes.Interface, ns string, pod *v1.Pod, time
 }
 
 func newZookeeperServiceManifest(svcName, clusterName, clusterIP string, ports []v1.ServicePort) *v1.Service {
-	labels := LabelsForCluster(clusterName)
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   svcName,
-			Labels: labels,
+			Labels: LabelsForCluster(clusterName),
 			Annotations: map[string]string{
 				TolerateUnreadyEndpointsAnnotation: "true",
 			},
 		},
 		Spec: v1.ServiceSpec{
 			Ports:     ports,
-			Selector:  labels,
+			Selector:  LabelsForCluster(clusterName),
 			ClusterIP: clusterIP,
 		},
 	}
 	return svc
 }
 
-// AddZookeeperVolumeToPod abstract the process of appending volume spec to pod spec
-func AddZookeeperVolumeToPod(pod *v1.Pod, pvc *v1.PersistentVolumeClaim) {
-	vol := v1.Volume{Name: zookeeperDataVolumeName}
+// AddZookeeperVolumeToPod abstract the process of appending volume spec to pod spec.
+// When only the data volume is backed by a PVC the transaction log is kept on the same
+// claim, so a replaced member comes back with both its snapshots and its txn log.
+func AddZookeeperVolumeToPod(pod *v1.Pod, dataPVC, tlogPVC *v1.PersistentVolumeClaim) {
+	pod.Spec.Volumes = append(pod.Spec.Volumes, newZookeeperVolume(zookeeperDataVolumeName, dataPVC))
+	if tlogPVC == nil && dataPVC != nil {
+		moveTlogToDataVolume(pod)
+		return
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, newZookeeperVolume(zookeeperTlogVolumeName, tlogPVC))
+}
+
+func newZookeeperVolume(name string, pvc *v1.PersistentVolumeClaim) v1.Volume {
+	vol := v1.Volume{Name: name}
 	if pvc != nil {
 		vol.VolumeSource = v1.VolumeSource{
 			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name},
@@ -214,18 +478,70 @@ func AddZookeeperVolumeToPod(pod *v1.Pod, pvc *v1.PersistentVolumeClaim) {
 	} else {
 		vol.VolumeSource = v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}
 	}
-	pod.Spec.Volumes = append(pod.Spec.Volumes, vol)
+	return vol
+}
+
+// NewZookeeperPodPVC returns the PVC holding the data directory of the given member.
+func NewZookeeperPodPVC(m *zookeeperutil.Member, pvcSpec v1.PersistentVolumeClaimSpec, clusterName, namespace string, owner metav1.OwnerReference) *v1.PersistentVolumeClaim {
+	return newZookeeperPVC(PVCNameFromMember(m.Name), m, pvcSpec, clusterName, namespace, owner)
+}
+
+// NewZookeeperTlogPVC returns the PVC holding the transaction log of the given member.
+func NewZookeeperTlogPVC(m *zookeeperutil.Member, pvcSpec v1.PersistentVolumeClaimSpec, clusterName, namespace string, owner metav1.OwnerReference) *v1.PersistentVolumeClaim {
+	return newZookeeperPVC(TlogPVCNameFromMember(m.Name), m, pvcSpec, clusterName, namespace, owner)
+}
+
+func newZookeeperPVC(name string, m *zookeeperutil.Member, pvcSpec v1.PersistentVolumeClaimSpec, clusterName, namespace string, owner metav1.OwnerReference) *v1.PersistentVolumeClaim {
+	labels := LabelsForCluster(clusterName)
+	labels["zookeeper_node"] = m.Name
+	labels[zookeeperRoleLabel] = m.Role()
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: pvcSpec,
+	}
+	addOwnerRefToObject(pvc.GetObjectMeta(), owner)
+	return pvc
 }
 
 func addOwnerRefToObject(o metav1.Object, r metav1.OwnerReference) {
 	o.SetOwnerReferences(append(o.GetOwnerReferences(), r))
 }
 
-func NewZookeeperPod(m *zookeeperutil.Member, existingCluster []string, clusterName, state string, cs api.ClusterSpec, owner metav1.OwnerReference) *v1.Pod {
+// MemberSpec returns the cluster spec the pods of participants or observers are created
+// from. Observers are created from the pod policy of the observer policy, if any.
+func MemberSpec(cs api.ClusterSpec, observer bool) api.ClusterSpec {
+	if observer && cs.Observers != nil && cs.Observers.Pod != nil {
+		cs.Pod = cs.Observers.Pod
+	}
+	return cs
+}
+
+// IsObserverPod tells whether the pod is the one of an observer. Pods without a role
+// label were created before observers were introduced and are participants.
+func IsObserverPod(pod *v1.Pod) bool {
+	return pod.Labels[zookeeperRoleLabel] == zookeeperutil.RoleObserver
+}
+
+// IsObserverPVC tells whether the PVC belongs to an observer.
+func IsObserverPVC(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc.Labels[zookeeperRoleLabel] == zookeeperutil.RoleObserver
+}
+
+func NewZookeeperPod(m *zookeeperutil.Member, existingCluster []string, clusterName, state string, cs api.ClusterSpec, quorumTLS api.QuorumTLSPhase, quorumSASL api.QuorumSASLPhase, owner metav1.OwnerReference) (*v1.Pod, error) {
+	cs = MemberSpec(cs, m.Observer)
+	hash, err := PodTemplateHash(cs, quorumTLS, quorumSASL)
+	if err != nil {
+		return nil, err
+	}
 	labels := map[string]string{
 		"app":          "zookeeper",
 		"zookeeper_node":    m.Name,
 		"zookeeper_cluster": clusterName,
+		zookeeperRoleLabel:  m.Role(),
 	}
 
 	livenessProbe := newZookeeperProbe()
@@ -236,16 +552,32 @@ func NewZookeeperPod(m *zookeeperutil.Member, existingCluster []string, clusterN
 	readinessProbe.FailureThreshold = 3
 
 	container := containerWithProbes(
-		zookeeperContainer(cs.Repository, cs.Version),
+		zookeeperContainer(ZookeeperImage(cs), cs.Service),
 		livenessProbe,
 		readinessProbe)
 
+	// The IP of a member addressed by pod IP is only known once the pod runs, the
+	// kubelet expands it in ZOO_SERVERS.
+	self := *m
+	self.PeerPort, self.LeaderPort = cs.Service.MemberPeerPort(), cs.Service.MemberLeaderPort()
+	if self.AddressType == zookeeperutil.AddressPodIP {
+		self.PodIP = "$(POD_IP)"
+		container.Env = append(container.Env, v1.EnvVar{
+			Name: "POD_IP",
+			ValueFrom: &v1.EnvVarSource{
+				FieldRef: &v1.ObjectFieldSelector{FieldPath: "status.podIP"},
+			},
+		})
+	}
 	zooServers := make([]string, len(existingCluster)+1)
 	copy(zooServers, existingCluster)
-	if state == "seed" || state == "replacement" {
-		zooServers[len(existingCluster)] = fmt.Sprintf("server.%d=%s:2888:3888:participant;%s:2181", m.ID(), m.Addr(), m.Addr())
+	localClientPort := cs.TLS.IsPlainClientPortDisabled()
+	// New participants join as observers and are promoted by the reconfiguration adding
+	// them to the ensemble, observers stay observers.
+	if (state == "seed" || state == "replacement") && !m.Observer {
+		zooServers[len(existingCluster)] = self.ServerConfig(zookeeperutil.RoleParticipant, localClientPort)
 	} else {
-		zooServers[len(existingCluster)] = fmt.Sprintf("server.%d=%s:2888:3888:observer;%s:2181", m.ID(), m.Addr(), m.Addr())
+		zooServers[len(existingCluster)] = self.ServerConfig(zookeeperutil.RoleObserver, localClientPort)
 	}
 
 	container.Env = append(container.Env, v1.EnvVar{
@@ -254,23 +586,18 @@ func NewZookeeperPod(m *zookeeperutil.Member, existingCluster []string, clusterN
 	}, v1.EnvVar{
 		Name:  "ZOO_SERVERS",
 		Value: strings.Join(zooServers, " "),
-	}, v1.EnvVar{
-		Name:  "ZOO_MAX_CLIENT_CNXNS",
-		Value: "0", // default 60
 	})
+	container.Env = append(container.Env, zookeeperConfigEnv(clusterName, cs, quorumTLS, quorumSASL)...)
+	if flags := jvmFlags(cs.JVM); len(flags) > 0 {
+		container.Env = append(container.Env, v1.EnvVar{
+			Name:  "JVMFLAGS",
+			Value: flags,
+		})
+	}
 	// Other available config items:
-	// - ZOO_TICK_TIME: 2000
-	// - ZOO_INIT_LIMIT: 5
-	// - ZOO_SYNC_LIMIT: 2
 	// - ZOO_STANDALONE_ENABLED: false (don't change this or you'll have a bad time)
 	// - ZOO_RECONFIG_ENABLED: true (don't change this or you'll have a bad time)
-	// - ZOO_SKIP_ACL: true
-	// - ZOO_4LW_WHITELIST: ruok (probes will fail if ruok is removed)
-
-	volumes := []v1.Volume{
-		{Name: "zookeeper-data", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
-		{Name: "zookeeper-tlog", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
-	}
+	// - ZOO_SKIP_ACL: true (set to false when SASL is enabled)
 
 	runAsNonRoot := true
 	podUID := int64(1000)
@@ -282,22 +609,8 @@ func NewZookeeperPod(m *zookeeperutil.Member, existingCluster []string, clusterN
 			Annotations: map[string]string{},
 		},
 		Spec: v1.PodSpec{
-			InitContainers: []v1.Container{{
-				// busybox:latest uses uclibc which contains a bug that sometimes prevents name resolution
-				// More info: https://github.com/docker-library/busybox/issues/27
-				//Image default: "busybox:1.28.0-glibc",
-				Image: imageNameBusybox(cs.Pod),
-				Name:  "check-dns",
-				// We bind to [hostname].[clustername].[namespace].svc which may take some time to appear in kubedns
-				Command: []string{"/bin/sh", "-c", fmt.Sprintf(`
-					while ( ! nslookup %s )
-					do
-						sleep 2
-					done`, m.Addr())},
-			}},
 			Containers:    []v1.Container{container},
 			RestartPolicy: v1.RestartPolicyNever,
-			Volumes:       volumes,
 			// DNS A record: `[m.Name].[clusterName].Namespace.svc`
 			// For example, zookeeper-795649v9kq in default namespace will have DNS name
 			// `zookeeper-795649v9kq.zookeeper.default.svc`.
@@ -311,10 +624,35 @@ func NewZookeeperPod(m *zookeeperutil.Member, existingCluster []string, clusterN
 			},
 		},
 	}
+	if m.AddressType != zookeeperutil.AddressPodIP {
+		pod.Spec.InitContainers = []v1.Container{{
+			// busybox:latest uses uclibc which contains a bug that sometimes prevents name resolution
+			// More info: https://github.com/docker-library/busybox/issues/27
+			//Image default: "busybox:1.28.0-glibc",
+			Image: imageNameBusybox(cs.Pod),
+			Name:  "check-dns",
+			// We bind to the name of the member which may take some time to appear in kubedns
+			Command: []string{"/bin/sh", "-c", fmt.Sprintf(`
+					while ( ! nslookup %s )
+					do
+						sleep 2
+					done`, m.Addr())},
+		}}
+	}
+	if cs.TLS.IsSecureClient() {
+		addSecureClientToPod(pod, cs.TLS)
+	}
+	if quorumTLS != api.QuorumTLSPhaseNone {
+		addQuorumTLSToPod(pod, cs.TLS)
+	}
+	if cs.Auth.IsSASLEnabled() {
+		addSASLToPod(pod, clusterName)
+	}
 	SetZookeeperVersion(pod, cs.Version)
 	applyPodPolicy(clusterName, pod, cs.Pod)
+	setZookeeperPodTemplateHash(pod, hash)
 	addOwnerRefToObject(pod.GetObjectMeta(), owner)
-	return pod
+	return pod, nil
 }
 
 func MustNewKubeClient() kubernetes.Interface {
@@ -0,0 +1,243 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodTemplateHash(t *testing.T) {
+	claim := func(size string) *v1.PersistentVolumeClaimSpec {
+		return &v1.PersistentVolumeClaimSpec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		}
+	}
+	base := api.ClusterSpec{Size: 3, Version: "3.5.3-beta", Pod: &api.PodPolicy{PersistentVolumeClaimSpec: claim("1Gi")}}
+	tests := []struct {
+		name      string
+		update    func(cs *api.ClusterSpec)
+		phase     api.QuorumTLSPhase
+		saslPhase api.QuorumSASLPhase
+		wEqual    bool
+	}{{
+		name:   "unchanged",
+		update: func(cs *api.ClusterSpec) {},
+		wEqual: true,
+	}, {
+		name:   "size and version are not part of the pod template",
+		update: func(cs *api.ClusterSpec) { cs.Size, cs.Version = 5, "3.5.4-beta" },
+		wEqual: true,
+	}, {
+		name:   "claim template spec only applies to new PVCs",
+		update: func(cs *api.ClusterSpec) { cs.Pod.PersistentVolumeClaimSpec = claim("10Gi") },
+		wEqual: true,
+	}, {
+		name:   "removing the claim template",
+		update: func(cs *api.ClusterSpec) { cs.Pod.PersistentVolumeClaimSpec = nil },
+		wEqual: false,
+	}, {
+		name:   "adding a tlog claim template",
+		update: func(cs *api.ClusterSpec) { cs.Pod.TlogPersistentVolumeClaimSpec = claim("1Gi") },
+		wEqual: false,
+	}, {
+		name:   "pod labels",
+		update: func(cs *api.ClusterSpec) { cs.Pod.Labels = map[string]string{"team": "a"} },
+		wEqual: false,
+	}, {
+		name: "unset fields of kubernetes types",
+		update: func(cs *api.ClusterSpec) {
+			cs.Pod.Affinity = &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{}}
+			cs.Pod.Resources = v1.ResourceRequirements{Limits: v1.ResourceList{}}
+			cs.Pod.ZookeeperEnv = []v1.EnvVar{}
+		},
+		wEqual: true,
+	}, {
+		name: "resources",
+		update: func(cs *api.ClusterSpec) {
+			cs.Pod.Resources = v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")}}
+		},
+		wEqual: false,
+	}, {
+		name:   "jvm",
+		update: func(cs *api.ClusterSpec) { cs.JVM = &api.JVMPolicy{HeapSizeInMB: 512} },
+		wEqual: false,
+	}, {
+		name: "peer secret before the quorum migration starts",
+		update: func(cs *api.ClusterSpec) {
+			cs.TLS = &api.TLSPolicy{Static: &api.StaticTLS{Member: &api.MemberSecret{PeerSecret: "peer-tls"}}}
+		},
+		wEqual: true,
+	}, {
+		name: "certificate expiry warning",
+		update: func(cs *api.ClusterSpec) {
+			cs.TLS = &api.TLSPolicy{Static: &api.StaticTLS{Member: &api.MemberSecret{}, ExpiryWarningInDays: 7}}
+		},
+		wEqual: true,
+	}, {
+		name:   "quorum TLS phase",
+		update: func(cs *api.ClusterSpec) {},
+		phase:  api.QuorumTLSPhasePortUnification,
+		wEqual: false,
+	}, {
+		name:      "quorum SASL phase",
+		update:    func(cs *api.ClusterSpec) {},
+		saslPhase: api.QuorumSASLPhaseLearnerRequired,
+		wEqual:    false,
+	}}
+	want, err := PodTemplateHash(base, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		cs := base
+		pod := *base.Pod
+		cs.Pod = &pod
+		tt.update(&cs)
+		hash, err := PodTemplateHash(cs, tt.phase, tt.saslPhase)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if (hash == want) != tt.wEqual {
+			t.Errorf("%s: hash equal get=%v, want=%v", tt.name, hash == want, tt.wEqual)
+		}
+	}
+}
+
+// The hash of a spec must not change with the client library, or upgrading the operator
+// restarts every member of every cluster. An intended change bumps podTemplateHashVersion
+// and the hash below.
+func TestPodTemplateHashStable(t *testing.T) {
+	seconds := int64(60)
+	cs := api.ClusterSpec{
+		Repository: "zookeeper",
+		Pod: &api.PodPolicy{
+			Labels:       map[string]string{"team": "a"},
+			NodeSelector: map[string]string{"disk": "ssd"},
+			Affinity: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a", "b"}}}}},
+			}}},
+			AntiAffinity: true,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m"), v1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			Tolerations:               []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "zk", Effect: v1.TaintEffectNoExecute, TolerationSeconds: &seconds}},
+			ZookeeperEnv:              []v1.EnvVar{{Name: "ZK_LOG_LEVEL", Value: "WARN"}},
+			PersistentVolumeClaimSpec: &v1.PersistentVolumeClaimSpec{},
+			ImagePullSecrets:          []v1.LocalObjectReference{{Name: "registry"}},
+		},
+		JVM: &api.JVMPolicy{HeapSizeInMB: 512},
+	}
+	hash, err := PodTemplateHash(cs, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0ad06593"; hash != want {
+		t.Errorf("hash get=%s, want=%s", hash, want)
+	}
+}
+
+func TestPodTemplateHashRequireQuorumSASL(t *testing.T) {
+	cs := api.ClusterSpec{Auth: &api.AuthPolicy{SASL: &api.SASLPolicy{SuperUserSecret: "super", QuorumSecret: "quorum"}}}
+	want, err := PodTemplateHash(cs, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The requirement reaches the pods through the quorum SASL phase only.
+	cs.Auth = &api.AuthPolicy{SASL: &api.SASLPolicy{SuperUserSecret: "super", QuorumSecret: "quorum", RequireQuorumSASL: true}}
+	hash, err := PodTemplateHash(cs, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != want {
+		t.Errorf("hash changed with requireQuorumSasl before the phase changed")
+	}
+}
+
+func TestAddZookeeperVolumeToPod(t *testing.T) {
+	m := &zookeeperutil.Member{Name: "example-1", Namespace: "default"}
+	dataPVC := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: PVCNameFromMember(m.Name)}}
+	tlogPVC := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: TlogPVCNameFromMember(m.Name)}}
+	tests := []struct {
+		name     string
+		dataPVC  *v1.PersistentVolumeClaim
+		tlogPVC  *v1.PersistentVolumeClaim
+		wClaims  map[string]string
+		wMounts  []string
+		wDataLog string
+	}{{
+		name:    "no PVC",
+		wClaims: map[string]string{},
+		wMounts: []string{"zookeeper-data", "zookeeper-tlog"},
+	}, {
+		name:     "transaction log on the data PVC",
+		dataPVC:  dataPVC,
+		wClaims:  map[string]string{"zookeeper-data": dataPVC.Name},
+		wMounts:  []string{"zookeeper-data"},
+		wDataLog: "/data/datalog",
+	}, {
+		name:     "separate transaction log PVC",
+		dataPVC:  dataPVC,
+		tlogPVC:  tlogPVC,
+		wClaims:  map[string]string{"zookeeper-data": dataPVC.Name, "zookeeper-tlog": tlogPVC.Name},
+		wMounts:  []string{"zookeeper-data", "zookeeper-tlog"},
+		wDataLog: "",
+	}}
+	for _, tt := range tests {
+		pod, err := NewZookeeperPod(m, nil, "example", "new", api.ClusterSpec{Version: "3.5.3-beta"}, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone, metav1.OwnerReference{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		AddZookeeperVolumeToPod(pod, tt.dataPVC, tt.tlogPVC)
+
+		claims := map[string]string{}
+		for _, vol := range pod.Spec.Volumes {
+			if vol.PersistentVolumeClaim != nil {
+				claims[vol.Name] = vol.PersistentVolumeClaim.ClaimName
+			}
+		}
+		if !reflect.DeepEqual(claims, tt.wClaims) {
+			t.Errorf("%s: claims get=%v, want=%v", tt.name, claims, tt.wClaims)
+		}
+		c := pod.Spec.Containers[0]
+		var mounts []string
+		for _, vm := range c.VolumeMounts {
+			if vm.Name == zookeeperDataVolumeName || vm.Name == zookeeperTlogVolumeName {
+				mounts = append(mounts, vm.Name)
+			}
+		}
+		if !reflect.DeepEqual(mounts, tt.wMounts) {
+			t.Errorf("%s: mounts get=%v, want=%v", tt.name, mounts, tt.wMounts)
+		}
+		var dataLog string
+		for _, env := range c.Env {
+			if env.Name == "ZOO_DATA_LOG_DIR" {
+				dataLog = env.Value
+			}
+		}
+		if dataLog != tt.wDataLog {
+			t.Errorf("%s: ZOO_DATA_LOG_DIR get=%q, want=%q", tt.name, dataLog, tt.wDataLog)
+		}
+	}
+}
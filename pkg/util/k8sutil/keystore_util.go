@@ -0,0 +1,126 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package k8sutil
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	jksMagic = 0xfeedfeed
+
+	jksPrivateKeyEntry   = 1
+	jksTrustedCertEntry  = 2
+	maxKeystoreBlockSize = 1 << 20
+)
+
+// keystoreCertificates returns the certificates of a JKS keystore: the chains of its
+// private keys and its trusted certificates. The keys are left encrypted and the
+// integrity of the keystore is not checked, so no password is needed.
+func keystoreCertificates(data []byte) ([]*x509.Certificate, error) {
+	r := bytes.NewReader(data)
+	var header struct{ Magic, Version, Count uint32 }
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read keystore header: %v", err)
+	}
+	if header.Magic != jksMagic {
+		return nil, errors.New("not a JKS keystore")
+	}
+	if header.Version != 1 && header.Version != 2 {
+		return nil, fmt.Errorf("unsupported JKS keystore version %d", header.Version)
+	}
+
+	var certs []*x509.Certificate
+	readCert := func() error {
+		if header.Version == 2 {
+			// The certificate type, always X.509.
+			if _, err := readJKSBlock(r, 2); err != nil {
+				return err
+			}
+		}
+		der, err := readJKSBlock(r, 4)
+		if err != nil {
+			return err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+		return nil
+	}
+	for i := uint32(0); i < header.Count; i++ {
+		var tag uint32
+		if err := binary.Read(r, binary.BigEndian, &tag); err != nil {
+			return nil, fmt.Errorf("failed to read keystore entry: %v", err)
+		}
+		// The alias and the creation time.
+		if _, err := readJKSBlock(r, 2); err != nil {
+			return nil, fmt.Errorf("failed to read keystore entry: %v", err)
+		}
+		if _, err := r.Seek(8, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+
+		switch tag {
+		case jksPrivateKeyEntry:
+			if _, err := readJKSBlock(r, 4); err != nil {
+				return nil, fmt.Errorf("failed to read keystore private key: %v", err)
+			}
+			var n uint32
+			if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+				return nil, fmt.Errorf("failed to read keystore certificate chain: %v", err)
+			}
+			for j := uint32(0); j < n; j++ {
+				if err := readCert(); err != nil {
+					return nil, fmt.Errorf("failed to read keystore certificate chain: %v", err)
+				}
+			}
+		case jksTrustedCertEntry:
+			if err := readCert(); err != nil {
+				return nil, fmt.Errorf("failed to read keystore certificate: %v", err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported keystore entry type %d", tag)
+		}
+	}
+	return certs, nil
+}
+
+// readJKSBlock reads a block of a JKS keystore prefixed by its length on lenSize bytes.
+func readJKSBlock(r io.Reader, lenSize int) ([]byte, error) {
+	var n uint32
+	if lenSize == 2 {
+		var n16 uint16
+		if err := binary.Read(r, binary.BigEndian, &n16); err != nil {
+			return nil, err
+		}
+		n = uint32(n16)
+	} else if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > maxKeystoreBlockSize {
+		return nil, fmt.Errorf("keystore block of %d bytes is too large", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
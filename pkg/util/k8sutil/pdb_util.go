@@ -0,0 +1,113 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PDBName returns the name of the pod disruption budget of the cluster with the given
+// maxUnavailable. A changed budget gets a new name, so that it can be created before
+// the outdated one is deleted.
+func PDBName(clusterName string, maxUnavailable int) string {
+	return fmt.Sprintf("%s-pdb-%d", clusterName, maxUnavailable)
+}
+
+// PDBMaxUnavailable returns how many members of an ensemble of the given size can be
+// down while a majority keeps serving.
+func PDBMaxUnavailable(size int) int {
+	if size < 1 {
+		return 0
+	}
+	return (size - 1) / 2
+}
+
+func newZookeeperPDB(clusterName string, size int, owner metav1.OwnerReference) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(PDBMaxUnavailable(size))
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   PDBName(clusterName, PDBMaxUnavailable(size)),
+			Labels: LabelsForCluster(clusterName),
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			// Observers do not vote, evicting them never costs the majority.
+			Selector: &metav1.LabelSelector{
+				MatchLabels: LabelsForCluster(clusterName),
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      zookeeperRoleLabel,
+					Operator: metav1.LabelSelectorOpNotIn,
+					Values:   []string{zookeeperutil.RoleObserver},
+				}},
+			},
+		},
+	}
+	addOwnerRefToObject(pdb.GetObjectMeta(), owner)
+	return pdb
+}
+
+// ReconcilePDB makes sure the pod disruption budget of the cluster lets at most a minority
+// of the given ensemble size be evicted. The size only counts the participants. The spec
+// of a pod disruption budget cannot be updated, so an outdated one is replaced: the new
+// one is created first, so that the pods are never left without a budget. Evictions fail
+// while both select the pods, as a pod with more than one budget cannot be evicted.
+func ReconcilePDB(kubecli kubernetes.Interface, clusterName, ns string, size int, owner metav1.OwnerReference) error {
+	want := newZookeeperPDB(clusterName, size, owner)
+	pdbs := kubecli.PolicyV1beta1().PodDisruptionBudgets(ns)
+	list, err := pdbs.List(ClusterListOpt(clusterName))
+	if err != nil {
+		return fmt.Errorf("failed to list pod disruption budgets: %v", err)
+	}
+	upToDate := false
+	var outdated []string
+	for i := range list.Items {
+		pdb := &list.Items[i]
+		if pdb.Name == want.Name && pdb.Spec.MaxUnavailable != nil && *pdb.Spec.MaxUnavailable == *want.Spec.MaxUnavailable &&
+			reflect.DeepEqual(pdb.Spec.Selector, want.Spec.Selector) {
+			upToDate = true
+			continue
+		}
+		if pdb.Name == want.Name {
+			// Only a budget edited by hand ends up here. It is replaced in place.
+			err = pdbs.Delete(pdb.Name, &metav1.DeleteOptions{})
+			if err != nil && !IsKubernetesResourceNotFoundError(err) {
+				return fmt.Errorf("failed to delete outdated pod disruption budget (%s): %v", pdb.Name, err)
+			}
+			continue
+		}
+		outdated = append(outdated, pdb.Name)
+	}
+	if !upToDate {
+		_, err = pdbs.Create(want)
+		if err != nil && !IsKubernetesResourceAlreadyExistError(err) {
+			return fmt.Errorf("failed to create pod disruption budget: %v", err)
+		}
+	}
+	for _, name := range outdated {
+		err = pdbs.Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !IsKubernetesResourceNotFoundError(err) {
+			return fmt.Errorf("failed to delete outdated pod disruption budget (%s): %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPDBMaxUnavailable(t *testing.T) {
+	tests := []struct {
+		size            int
+		wMaxUnavailable int
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 0},
+		{3, 1},
+		{4, 1},
+		{5, 2},
+		{7, 3},
+	}
+	for _, tt := range tests {
+		if n := PDBMaxUnavailable(tt.size); n != tt.wMaxUnavailable {
+			t.Errorf("size %d: max unavailable get=%d, want=%d", tt.size, n, tt.wMaxUnavailable)
+		}
+	}
+}
+
+func TestReconcilePDB(t *testing.T) {
+	ns := metav1.NamespaceDefault
+	kubecli := fake.NewSimpleClientset()
+	pdbNames := func() []string {
+		list, err := kubecli.PolicyV1beta1().PodDisruptionBudgets(ns).List(ClusterListOpt("example"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, pdb := range list.Items {
+			names = append(names, pdb.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// A budget from before the budgets were named after their maxUnavailable is replaced.
+	legacy := newZookeeperPDB("example", 3, metav1.OwnerReference{})
+	legacy.Name = "example-pdb"
+	if _, err := kubecli.PolicyV1beta1().PodDisruptionBudgets(ns).Create(legacy); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		size   int
+		wNames []string
+	}{
+		{size: 3, wNames: []string{"example-pdb-1"}},
+		{size: 3, wNames: []string{"example-pdb-1"}},
+		{size: 4, wNames: []string{"example-pdb-1"}},
+		{size: 5, wNames: []string{"example-pdb-2"}},
+		{size: 1, wNames: []string{"example-pdb-0"}},
+	}
+	for i, tt := range tests {
+		if err := ReconcilePDB(kubecli, "example", ns, tt.size, metav1.OwnerReference{}); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		names := pdbNames()
+		if len(names) != len(tt.wNames) || names[0] != tt.wNames[0] {
+			t.Errorf("#%d: size %d: budgets get=%v, want=%v", i, tt.size, names, tt.wNames)
+		}
+	}
+}
@@ -17,6 +17,8 @@ package k8sutil
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
 	//"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
@@ -36,10 +38,34 @@ func zookeeperVolumeMounts() []v1.VolumeMount {
 	}
 }
 
-func zookeeperContainer(repo, version string) v1.Container {
+// moveTlogToDataVolume drops the dedicated transaction log volume of the zookeeper
+// container and points the transaction log at a directory of the data volume instead.
+func moveTlogToDataVolume(pod *v1.Pod) {
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != "zookeeper" {
+			continue
+		}
+		mounts := c.VolumeMounts[:0]
+		for _, vm := range c.VolumeMounts {
+			if vm.Name != zookeeperTlogVolumeName {
+				mounts = append(mounts, vm)
+			}
+		}
+		c.VolumeMounts = mounts
+		c.Env = append(c.Env, v1.EnvVar{
+			Name:  "ZOO_DATA_LOG_DIR",
+			Value: zookeeperDataVolumeMountDir + zookeeperTlogVolumeMountDir,
+		})
+	}
+}
+
+// zookeeperContainer returns the zookeeper container, listening on the quorum and leader
+// election ports of the service policy.
+func zookeeperContainer(image string, sp *api.ServicePolicy) v1.Container {
 	c := v1.Container{
 		Name:    "zookeeper",
-		Image:   ImageName(repo, version),
+		Image:   image,
 		Ports: []v1.ContainerPort{
 			{
 				Name:          "client",
@@ -48,12 +74,12 @@ func zookeeperContainer(repo, version string) v1.Container {
 			},
 			{
 				Name:          "peer",
-				ContainerPort: int32(2888),
+				ContainerPort: int32(sp.MemberPeerPort()),
 				Protocol:      v1.ProtocolTCP,
 			},
 			{
 				Name:          "server",
-				ContainerPort: int32(3888),
+				ContainerPort: int32(sp.MemberLeaderPort()),
 				Protocol:      v1.ProtocolTCP,
 			},
 			{
@@ -68,6 +94,114 @@ func zookeeperContainer(repo, version string) v1.Container {
 	return c
 }
 
+// requiredFourLetterWords are the four letter words the operator depends on.
+var requiredFourLetterWords = []string{"ruok", "srvr", "mntr"}
+
+// zookeeperConfigEnv translates the config, TLS and auth sections of the spec into the
+// environment of the zookeeper container. Settings without a ZOO_* variable are passed
+// to the JVM as system properties or appended to zoo.cfg.
+func zookeeperConfigEnv(clusterName string, cs api.ClusterSpec, quorumTLS api.QuorumTLSPhase, quorumSASL api.QuorumSASLPhase) []v1.EnvVar {
+	cfg, tp := cs.Config, cs.TLS
+	if cfg == nil {
+		cfg = &api.ZookeeperConfig{}
+	}
+	var env []v1.EnvVar
+	addInt := func(name string, value int) {
+		if value != 0 {
+			env = append(env, v1.EnvVar{Name: name, Value: strconv.Itoa(value)})
+		}
+	}
+	addInt("ZOO_TICK_TIME", cfg.TickTime)
+	addInt("ZOO_INIT_LIMIT", cfg.InitLimit)
+	addInt("ZOO_SYNC_LIMIT", cfg.SyncLimit)
+
+	maxClientCnxns := 0 // zookeeper default is 60
+	if cfg.MaxClientCnxns != nil {
+		maxClientCnxns = *cfg.MaxClientCnxns
+	}
+	env = append(env, v1.EnvVar{Name: "ZOO_MAX_CLIENT_CNXNS", Value: strconv.Itoa(maxClientCnxns)})
+
+	addInt("ZOO_AUTOPURGE_SNAPRETAINCOUNT", cfg.AutoPurgeSnapRetainCount)
+	addInt("ZOO_AUTOPURGE_PURGEINTERVAL", cfg.AutoPurgePurgeInterval)
+
+	env = append(env, v1.EnvVar{Name: "ZOO_4LW_WHITELIST", Value: fourLetterWordsWhitelist(cfg.FourLetterWordsWhitelist)})
+
+	var props []string
+	addProp := func(name string, value int) {
+		if value != 0 {
+			props = append(props, fmt.Sprintf("-Dzookeeper.%s=%d", name, value))
+		}
+	}
+	addProp("globalOutstandingLimit", cfg.GlobalOutstandingLimit)
+	addProp("preAllocSize", cfg.PreAllocSize)
+	addProp("snapCount", cfg.SnapCount)
+
+	var extra []string
+	if tp.IsSecureClient() {
+		env = append(env, secureClientEnv(tp)...)
+		extra = append(extra, fmt.Sprintf("secureClientPort=%d", ZookeeperSecureClientPort))
+		props = append(props, secureClientProps()...)
+	}
+	if quorumTLS != api.QuorumTLSPhaseNone {
+		env = append(env, quorumTLSEnv(tp)...)
+		extra = append(extra, quorumTLSConfig(quorumTLS, tp)...)
+		props = append(props, quorumTLSProps()...)
+	}
+	if cs.Auth.IsSASLEnabled() {
+		env = append(env, saslEnv(clusterName)...)
+		extra = append(extra, quorumSASLConfig(cs.Auth, quorumSASL)...)
+		props = append(props, saslProps()...)
+	}
+	if len(extra) != 0 {
+		env = append(env, v1.EnvVar{Name: "ZOO_CFG_EXTRA", Value: strings.Join(extra, " ")})
+	}
+	if len(props) != 0 {
+		env = append(env, v1.EnvVar{Name: "SERVER_JVMFLAGS", Value: strings.Join(props, " ")})
+	}
+	return env
+}
+
+// fourLetterWordsWhitelist adds the four letter words required by the operator to the given whitelist.
+func fourLetterWordsWhitelist(words []string) string {
+	whitelist := append([]string{}, words...)
+	for _, w := range words {
+		if w == "*" {
+			return "*"
+		}
+	}
+	for _, required := range requiredFourLetterWords {
+		found := false
+		for _, w := range words {
+			if w == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			whitelist = append(whitelist, required)
+		}
+	}
+	return strings.Join(whitelist, ",")
+}
+
+// jvmFlags translates the JVM policy into the flags passed to the zookeeper JVM.
+func jvmFlags(policy *api.JVMPolicy) string {
+	if policy == nil {
+		return ""
+	}
+	var flags []string
+	if policy.HeapSizeInMB > 0 {
+		flags = append(flags, fmt.Sprintf("-Xms%dm", policy.HeapSizeInMB), fmt.Sprintf("-Xmx%dm", policy.HeapSizeInMB))
+	}
+	if policy.NewGenSizeInMB > 0 {
+		flags = append(flags, fmt.Sprintf("-Xmn%dm", policy.NewGenSizeInMB))
+	}
+	if policy.TenuringThreshold != nil {
+		flags = append(flags, fmt.Sprintf("-XX:MaxTenuringThreshold=%d", *policy.TenuringThreshold))
+	}
+	return strings.Join(flags, " ")
+}
+
 func containerWithProbes(c v1.Container, lp *v1.Probe, rp *v1.Probe) v1.Container {
 	c.LivenessProbe = lp
 	c.ReadinessProbe = rp
@@ -112,8 +246,13 @@ func applyPodPolicy(clusterName string, pod *v1.Pod, policy *api.PodPolicy) {
 
 	mergeLabels(pod.Labels, policy.Labels)
 
+	if len(policy.ImagePullSecrets) != 0 {
+		pod.Spec.ImagePullSecrets = policy.ImagePullSecrets
+	}
+
 	for i := range pod.Spec.Containers {
 		pod.Spec.Containers[i] = containerWithRequirements(pod.Spec.Containers[i], policy.Resources)
+		pod.Spec.Containers[i].ImagePullPolicy = policy.ImagePullPolicy
 		if pod.Spec.Containers[i].Name == "zookeeper" {
 			pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, policy.ZookeeperEnv...)
 		}
@@ -121,6 +260,7 @@ func applyPodPolicy(clusterName string, pod *v1.Pod, policy *api.PodPolicy) {
 
 	for i := range pod.Spec.InitContainers {
 		pod.Spec.InitContainers[i] = containerWithRequirements(pod.Spec.InitContainers[i], policy.Resources)
+		pod.Spec.InitContainers[i].ImagePullPolicy = policy.ImagePullPolicy
 	}
 
 	for key, value := range policy.Annotations {
@@ -150,3 +290,29 @@ func PodSpecToPrettyJSON(pod *v1.Pod) (string, error) {
 	}
 	return string(bytes), nil
 }
+
+// PreferTopologyDomainForPod makes the scheduler prefer the nodes of the given topology
+// domain for the pod, on top of the node affinity of the pod policy. The pod still
+// runs elsewhere if the domain has no room for it, or if its volume is bound to
+// another domain.
+func PreferTopologyDomainForPod(pod *v1.Pod, topologyKey, domain string) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &v1.Affinity{}
+	} else {
+		pod.Spec.Affinity = pod.Spec.Affinity.DeepCopy()
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+	na := pod.Spec.Affinity.NodeAffinity
+	na.PreferredDuringSchedulingIgnoredDuringExecution = append(na.PreferredDuringSchedulingIgnoredDuringExecution, v1.PreferredSchedulingTerm{
+		Weight: 100,
+		Preference: v1.NodeSelectorTerm{
+			MatchExpressions: []v1.NodeSelectorRequirement{{
+				Key:      topologyKey,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{domain},
+			}},
+		},
+	})
+}
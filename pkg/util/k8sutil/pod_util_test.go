@@ -0,0 +1,163 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"strings"
+	"testing"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestJVMFlags(t *testing.T) {
+	zero, four := 0, 4
+	tests := []struct {
+		policy *api.JVMPolicy
+		wFlags string
+	}{{
+		policy: nil,
+		wFlags: "",
+	}, {
+		policy: &api.JVMPolicy{},
+		wFlags: "",
+	}, {
+		policy: &api.JVMPolicy{HeapSizeInMB: 512},
+		wFlags: "-Xms512m -Xmx512m",
+	}, {
+		policy: &api.JVMPolicy{HeapSizeInMB: 1024, NewGenSizeInMB: 256, TenuringThreshold: &four},
+		wFlags: "-Xms1024m -Xmx1024m -Xmn256m -XX:MaxTenuringThreshold=4",
+	}, {
+		policy: &api.JVMPolicy{TenuringThreshold: &zero},
+		wFlags: "-XX:MaxTenuringThreshold=0",
+	}}
+	for i, tt := range tests {
+		flags := jvmFlags(tt.policy)
+		if flags != tt.wFlags {
+			t.Errorf("#%d: flags get=%q, want=%q", i, flags, tt.wFlags)
+		}
+	}
+}
+
+func TestFourLetterWordsWhitelist(t *testing.T) {
+	tests := []struct {
+		words      []string
+		wWhitelist string
+	}{{
+		words:      nil,
+		wWhitelist: "ruok,srvr,mntr",
+	}, {
+		words:      []string{"ruok"},
+		wWhitelist: "ruok,srvr,mntr",
+	}, {
+		words:      []string{"stat", "mntr"},
+		wWhitelist: "stat,mntr,ruok,srvr",
+	}, {
+		words:      []string{"stat", "*"},
+		wWhitelist: "*",
+	}}
+	for i, tt := range tests {
+		whitelist := fourLetterWordsWhitelist(tt.words)
+		if whitelist != tt.wWhitelist {
+			t.Errorf("#%d: whitelist get=%q, want=%q", i, whitelist, tt.wWhitelist)
+		}
+	}
+}
+
+func TestZookeeperConfigEnvSecureClient(t *testing.T) {
+	tp := &api.TLSPolicy{Static: &api.StaticTLS{Member: &api.MemberSecret{ServerSecret: "server-tls"}}}
+	env := zookeeperConfigEnv("example", api.ClusterSpec{TLS: tp}, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone)
+
+	index := map[string]int{}
+	for i, e := range env {
+		index[e.Name] = i
+	}
+	jvmFlags, ok := index["SERVER_JVMFLAGS"]
+	if !ok {
+		t.Fatalf("SERVER_JVMFLAGS not set")
+	}
+	for _, name := range []string{keystorePasswordEnv, truststorePasswordEnv} {
+		i, ok := index[name]
+		if !ok {
+			t.Fatalf("%s not set", name)
+		}
+		if i > jvmFlags {
+			t.Errorf("%s is set after SERVER_JVMFLAGS", name)
+		}
+		if env[i].ValueFrom == nil || env[i].ValueFrom.SecretKeyRef.Name != "server-tls" {
+			t.Errorf("%s is not read from the server secret", name)
+		}
+	}
+	if !strings.Contains(env[jvmFlags].Value, "NettyServerCnxnFactory") {
+		t.Errorf("SERVER_JVMFLAGS get=%q, want the netty connection factory", env[jvmFlags].Value)
+	}
+	if i, ok := index["ZOO_CFG_EXTRA"]; !ok || env[i].Value != "secureClientPort=2281" {
+		t.Errorf("ZOO_CFG_EXTRA does not open the secure client port")
+	}
+}
+
+func TestQuorumTLSConfig(t *testing.T) {
+	tests := []struct {
+		phase  api.QuorumTLSPhase
+		wExtra string
+	}{{
+		phase:  api.QuorumTLSPhasePortUnification,
+		wExtra: "sslQuorum=false portUnification=true",
+	}, {
+		phase:  api.QuorumTLSPhaseSSLQuorumPortUnification,
+		wExtra: "sslQuorum=true portUnification=true",
+	}, {
+		phase:  api.QuorumTLSPhaseSSLQuorum,
+		wExtra: "sslQuorum=true portUnification=false",
+	}}
+	tp := &api.TLSPolicy{Static: &api.StaticTLS{Member: &api.MemberSecret{ServerSecret: "server-tls", PeerSecret: "peer-tls"}}}
+	for i, tt := range tests {
+		var extra string
+		for _, e := range zookeeperConfigEnv("example", api.ClusterSpec{TLS: tp}, tt.phase, api.QuorumSASLPhaseNone) {
+			if e.Name == "ZOO_CFG_EXTRA" {
+				extra = e.Value
+			}
+		}
+		wExtra := "secureClientPort=2281 " + tt.wExtra
+		if extra != wExtra {
+			t.Errorf("#%d: ZOO_CFG_EXTRA get=%q, want=%q", i, extra, wExtra)
+		}
+	}
+}
+
+func TestPreferTopologyDomainForPod(t *testing.T) {
+	required := &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+		MatchExpressions: []v1.NodeSelectorRequirement{{Key: "disk", Operator: v1.NodeSelectorOpIn, Values: []string{"ssd"}}},
+	}}}
+	affinity := &v1.Affinity{NodeAffinity: &v1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: required}}
+	pod := &v1.Pod{Spec: v1.PodSpec{Affinity: affinity}}
+	PreferTopologyDomainForPod(pod, "zone", "a")
+
+	na := pod.Spec.Affinity.NodeAffinity
+	if len(na.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions) != 1 {
+		t.Errorf("the required node affinity changed: %v", na.RequiredDuringSchedulingIgnoredDuringExecution)
+	}
+	if len(na.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Fatalf("preferred terms get=%d, want=1", len(na.PreferredDuringSchedulingIgnoredDuringExecution))
+	}
+	req := na.PreferredDuringSchedulingIgnoredDuringExecution[0].Preference.MatchExpressions[0]
+	if req.Key != "zone" || req.Operator != v1.NodeSelectorOpIn || len(req.Values) != 1 || req.Values[0] != "a" {
+		t.Errorf("preferred requirement get=%v", req)
+	}
+	if affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution != nil {
+		t.Errorf("the affinity of the pod policy was modified")
+	}
+}
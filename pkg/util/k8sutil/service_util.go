@@ -0,0 +1,202 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"reflect"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// externalServiceLabel marks the per member services of external access, which are
+// listed and garbage collected by it.
+const externalServiceLabel = "zookeeper_service"
+
+func ExternalServiceName(memberName string) string {
+	return memberName + "-external"
+}
+
+// ExternalServiceListOpt selects the external access services of the cluster.
+func ExternalServiceListOpt(clusterName string) metav1.ListOptions {
+	l := LabelsForCluster(clusterName)
+	l[externalServiceLabel] = "external"
+	return metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(l).String(),
+	}
+}
+
+// NewExternalService returns the service exposing the client ports of a single member
+// outside of the Kubernetes cluster.
+func NewExternalService(memberName, clusterName string, ea *api.ExternalAccessPolicy, tp *api.TLSPolicy, owner metav1.OwnerReference) *v1.Service {
+	l := LabelsForCluster(clusterName)
+	l[externalServiceLabel] = "external"
+	selector := LabelsForCluster(clusterName)
+	selector["zookeeper_node"] = memberName
+	annotations := map[string]string{}
+	for k, v := range ea.Annotations {
+		annotations[k] = v
+	}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ExternalServiceName(memberName),
+			Labels:      l,
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Type:     ea.Type,
+			Ports:    clientServicePorts(tp, nil),
+			Selector: selector,
+		},
+	}
+	addOwnerRefToObject(svc.GetObjectMeta(), owner)
+	return svc
+}
+
+// UpdateExternalService applies the type, annotations and ports of want to the existing
+// external service svc. It returns whether svc was changed.
+func UpdateExternalService(svc, want *v1.Service) bool {
+	return mergeService(svc, want)
+}
+
+// mergeService applies the type, labels, annotations, ports and source ranges of want to
+// the existing service svc. Node ports already allocated to svc are kept. Labels and
+// annotations dropped from want are left in place since other controllers annotate
+// services too. It returns whether svc was changed.
+func mergeService(svc, want *v1.Service) bool {
+	changed := false
+	if svc.Spec.Type != want.Spec.Type {
+		svc.Spec.Type = want.Spec.Type
+		changed = true
+	}
+	if svc.Labels == nil {
+		svc.Labels = map[string]string{}
+	}
+	if mergeMap(svc.Labels, want.Labels) {
+		changed = true
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = map[string]string{}
+	}
+	if mergeMap(svc.Annotations, want.Annotations) {
+		changed = true
+	}
+	if (len(svc.Spec.LoadBalancerSourceRanges) != 0 || len(want.Spec.LoadBalancerSourceRanges) != 0) &&
+		!reflect.DeepEqual(svc.Spec.LoadBalancerSourceRanges, want.Spec.LoadBalancerSourceRanges) {
+		svc.Spec.LoadBalancerSourceRanges = want.Spec.LoadBalancerSourceRanges
+		changed = true
+	}
+
+	nodePorts := map[string]int32{}
+	if want.Spec.Type == v1.ServiceTypeNodePort || want.Spec.Type == v1.ServiceTypeLoadBalancer {
+		for _, p := range svc.Spec.Ports {
+			nodePorts[p.Name] = p.NodePort
+		}
+	}
+	ports := make([]v1.ServicePort, len(want.Spec.Ports))
+	for i, p := range want.Spec.Ports {
+		p.NodePort = nodePorts[p.Name]
+		ports[i] = p
+	}
+	if len(ports) != len(svc.Spec.Ports) {
+		changed = true
+	} else {
+		for i := range ports {
+			if ports[i] != svc.Spec.Ports[i] {
+				changed = true
+			}
+		}
+	}
+	svc.Spec.Ports = ports
+	return changed
+}
+
+// mergeMap sets the entries of src in dst and returns whether dst was changed.
+func mergeMap(dst, src map[string]string) bool {
+	changed := false
+	for k, v := range src {
+		if dst[k] != v {
+			dst[k] = v
+			changed = true
+		}
+	}
+	return changed
+}
+
+// serviceClientPort returns the plaintext client port of the client service.
+func serviceClientPort(sp *api.ServicePolicy) int32 {
+	if sp == nil || sp.ClientPort == 0 {
+		return ZookeeperClientPort
+	}
+	return sp.ClientPort
+}
+
+// ClientServicePort returns the port clients connect to on the client service: the
+// secure client port once the plain client port is disabled, the plaintext client port
+// of the service policy otherwise.
+func ClientServicePort(cs api.ClusterSpec) int {
+	if cs.TLS.IsPlainClientPortDisabled() {
+		return ZookeeperSecureClientPort
+	}
+	return int(serviceClientPort(cs.Service))
+}
+
+// ExternalEndpoint returns the address the member is reachable at through its external
+// service. The host of a NodePort service is the address of the node running the
+// member, node may be nil if it is not known yet.
+func ExternalEndpoint(memberName string, svc *v1.Service, node *v1.Node) api.ExternalEndpoint {
+	ep := api.ExternalEndpoint{Member: memberName}
+	switch svc.Spec.Type {
+	case v1.ServiceTypeLoadBalancer:
+		if ingress := svc.Status.LoadBalancer.Ingress; len(ingress) > 0 {
+			ep.Host = ingress[0].IP
+			if ep.Host == "" {
+				ep.Host = ingress[0].Hostname
+			}
+		}
+	case v1.ServiceTypeNodePort:
+		if node != nil {
+			ep.Host = nodeAddress(node)
+		}
+	}
+	for _, p := range svc.Spec.Ports {
+		port := p.Port
+		if svc.Spec.Type == v1.ServiceTypeNodePort {
+			port = p.NodePort
+		}
+		switch p.Name {
+		case "client":
+			ep.ClientPort = port
+		case "secure-client":
+			ep.SecureClientPort = port
+		}
+	}
+	return ep
+}
+
+// nodeAddress prefers the external address of the node over its internal one.
+func nodeAddress(node *v1.Node) string {
+	for _, t := range []v1.NodeAddressType{v1.NodeExternalIP, v1.NodeInternalIP} {
+		for _, a := range node.Status.Addresses {
+			if a.Type == t {
+				return a.Address
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"strings"
+	"testing"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdateExternalService(t *testing.T) {
+	ea := &api.ExternalAccessPolicy{Type: v1.ServiceTypeNodePort}
+	svc := NewExternalService("example-1", "example", ea, nil, metav1.OwnerReference{})
+	svc.Spec.Ports[0].NodePort = 30181
+
+	want := NewExternalService("example-1", "example", ea, nil, metav1.OwnerReference{})
+	if UpdateExternalService(svc, want) {
+		t.Errorf("expected no change when only the node port was allocated")
+	}
+	if svc.Spec.Ports[0].NodePort != 30181 {
+		t.Errorf("expected the node port to be kept, got %d", svc.Spec.Ports[0].NodePort)
+	}
+
+	ea = &api.ExternalAccessPolicy{
+		Type:        v1.ServiceTypeLoadBalancer,
+		Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "0.0.0.0/0"},
+	}
+	want = NewExternalService("example-1", "example", ea, nil, metav1.OwnerReference{})
+	if !UpdateExternalService(svc, want) {
+		t.Fatalf("expected a change of type and annotations")
+	}
+	if svc.Spec.Type != v1.ServiceTypeLoadBalancer || len(svc.Annotations) != 1 {
+		t.Errorf("expected a load balancer with one annotation, got %s %v", svc.Spec.Type, svc.Annotations)
+	}
+}
+
+func TestExternalEndpoint(t *testing.T) {
+	ea := &api.ExternalAccessPolicy{Type: v1.ServiceTypeNodePort}
+	svc := NewExternalService("example-1", "example", ea, nil, metav1.OwnerReference{})
+	svc.Spec.Ports[0].NodePort = 30181
+	node := &v1.Node{Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "203.0.113.1"},
+	}}}
+	ep := ExternalEndpoint("example-1", svc, node)
+	if ep.Host != "203.0.113.1" || ep.ClientPort != 30181 || ep.SecureClientPort != 0 {
+		t.Errorf("unexpected node port endpoint: %+v", ep)
+	}
+
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: "lb.example.com"}}
+	ep = ExternalEndpoint("example-1", svc, nil)
+	if ep.Host != "lb.example.com" || ep.ClientPort != ZookeeperClientPort {
+		t.Errorf("unexpected load balancer endpoint: %+v", ep)
+	}
+}
+
+func TestMergeClientService(t *testing.T) {
+	svc := newZookeeperServiceManifest("example-client", "example", "", clientServicePorts(nil, nil))
+	svc.Spec.Type = v1.ServiceTypeClusterIP
+
+	sp := &api.ServicePolicy{
+		Type:                     v1.ServiceTypeLoadBalancer,
+		Labels:                   map[string]string{"team": "data"},
+		LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		ClientPort:               12181,
+	}
+	want := newZookeeperServiceManifest("example-client", "example", "", clientServicePorts(nil, sp))
+	want.Spec.Type = sp.Type
+	mergeLabels(want.Labels, sp.Labels)
+	want.Spec.LoadBalancerSourceRanges = sp.LoadBalancerSourceRanges
+	if !mergeService(svc, want) {
+		t.Fatalf("expected the service policy to change the service")
+	}
+	if svc.Labels["team"] != "data" || len(svc.Spec.LoadBalancerSourceRanges) != 1 {
+		t.Errorf("expected the labels and source ranges of the policy, got %v %v", svc.Labels, svc.Spec.LoadBalancerSourceRanges)
+	}
+	p := svc.Spec.Ports[0]
+	if p.Port != 12181 || p.TargetPort.IntValue() != ZookeeperClientPort {
+		t.Errorf("expected port 12181 mapped to %d, got %d to %s", ZookeeperClientPort, p.Port, p.TargetPort.String())
+	}
+	if svc.Spec.Selector["team"] != "" {
+		t.Errorf("expected the selector to be left alone, got %v", svc.Spec.Selector)
+	}
+
+	svc.Spec.Ports[0].NodePort = 31181
+	want = newZookeeperServiceManifest("example-client", "example", "", clientServicePorts(nil, sp))
+	want.Spec.Type = v1.ServiceTypeClusterIP
+	if !mergeService(svc, want) {
+		t.Fatalf("expected the type to change")
+	}
+	if svc.Spec.Ports[0].NodePort != 0 || len(svc.Spec.LoadBalancerSourceRanges) != 0 {
+		t.Errorf("expected a ClusterIP service to drop its node port and source ranges, got %v", svc.Spec)
+	}
+}
+
+func TestMemberPorts(t *testing.T) {
+	sp := &api.ServicePolicy{PeerPort: 12888, LeaderPort: 13888}
+	kubecli := fake.NewSimpleClientset()
+	if err := ReconcilePeerService(kubecli, "example", "default", sp, metav1.OwnerReference{}); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := kubecli.CoreV1().Services("default").Get("example", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &zookeeperutil.Member{Name: "example-1", Namespace: "default"}
+	pod, err := NewZookeeperPod(m, nil, "example", "seed", api.ClusterSpec{Version: "3.5.3-beta", Service: sp}, api.QuorumTLSPhaseNone, api.QuorumSASLPhaseNone, metav1.OwnerReference{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerPorts := map[int32]bool{}
+	for _, p := range pod.Spec.Containers[0].Ports {
+		containerPorts[p.ContainerPort] = true
+	}
+	for _, name := range []string{"peer", "leader"} {
+		var port v1.ServicePort
+		for _, p := range svc.Spec.Ports {
+			if p.Name == name {
+				port = p
+			}
+		}
+		if port.Port != port.TargetPort.IntVal || !containerPorts[port.Port] {
+			t.Errorf("%s port of the peer service get=%d to %s, want it on the port of the members", name, port.Port, port.TargetPort.String())
+		}
+	}
+	if !containerPorts[12888] || !containerPorts[13888] {
+		t.Errorf("container ports get=%v, want 12888 and 13888", containerPorts)
+	}
+	var servers string
+	for _, e := range pod.Spec.Containers[0].Env {
+		if e.Name == "ZOO_SERVERS" {
+			servers = e.Value
+		}
+	}
+	if !strings.Contains(servers, ":12888:13888:") {
+		t.Errorf("ZOO_SERVERS get=%q, want the ports of the service policy", servers)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	api "github.com/nuance-mobility/zookeeper-operator/pkg/apis/zookeeper/v1alpha1"
+	"github.com/nuance-mobility/zookeeper-operator/pkg/util/zookeeperutil"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	serverTLSVolumeName = "member-server-tls"
+	serverTLSDir        = "/tls"
+	peerTLSVolumeName   = "member-peer-tls"
+	peerTLSDir          = "/quorum-tls"
+
+	keystoreFile       = "keystore.jks"
+	truststoreFile     = "truststore.jks"
+	keystorePassword   = "keystore.password"
+	truststorePassword = "truststore.password"
+
+	keystorePasswordEnv         = "ZOO_TLS_KEYSTORE_PASSWORD"
+	truststorePasswordEnv       = "ZOO_TLS_TRUSTSTORE_PASSWORD"
+	quorumKeystorePasswordEnv   = "ZOO_QUORUM_TLS_KEYSTORE_PASSWORD"
+	quorumTruststorePasswordEnv = "ZOO_QUORUM_TLS_TRUSTSTORE_PASSWORD"
+
+	// Keys of the PEM encoded material in the operator secret.
+	tlsCertFile = "tls.crt"
+	tlsKeyFile  = "tls.key"
+	tlsCAFile   = "ca.crt"
+)
+
+// secureClientEnv returns the store passwords of the secure client port. They are read
+// from the server secret and must precede SERVER_JVMFLAGS, which refers to them.
+func secureClientEnv(tp *api.TLSPolicy) []v1.EnvVar {
+	secret := tp.Static.Member.ServerSecret
+	return []v1.EnvVar{
+		secretKeyEnv(keystorePasswordEnv, secret, keystorePassword),
+		secretKeyEnv(truststorePasswordEnv, secret, truststorePassword),
+	}
+}
+
+// quorumTLSEnv returns the store passwords of the quorum and election ports, read from
+// the peer secret.
+func quorumTLSEnv(tp *api.TLSPolicy) []v1.EnvVar {
+	secret := tp.Static.Member.PeerSecret
+	return []v1.EnvVar{
+		secretKeyEnv(quorumKeystorePasswordEnv, secret, keystorePassword),
+		secretKeyEnv(quorumTruststorePasswordEnv, secret, truststorePassword),
+	}
+}
+
+func secretKeyEnv(name, secret, key string) v1.EnvVar {
+	return v1.EnvVar{
+		Name: name,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: secret},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+// secureClientProps returns the system properties of the secure client port.
+// The secure client port requires the netty connection factory.
+func secureClientProps() []string {
+	return []string{
+		"-Dzookeeper.serverCnxnFactory=org.apache.zookeeper.server.NettyServerCnxnFactory",
+		fmt.Sprintf("-Dzookeeper.ssl.keyStore.location=%s/%s", serverTLSDir, keystoreFile),
+		fmt.Sprintf("-Dzookeeper.ssl.keyStore.password=$(%s)", keystorePasswordEnv),
+		fmt.Sprintf("-Dzookeeper.ssl.trustStore.location=%s/%s", serverTLSDir, truststoreFile),
+		fmt.Sprintf("-Dzookeeper.ssl.trustStore.password=$(%s)", truststorePasswordEnv),
+	}
+}
+
+// quorumTLSConfig returns the zoo.cfg settings of the given quorum TLS phase.
+func quorumTLSConfig(phase api.QuorumTLSPhase, tp *api.TLSPolicy) []string {
+	var cfg []string
+	switch phase {
+	case api.QuorumTLSPhasePortUnification:
+		cfg = []string{"sslQuorum=false", "portUnification=true"}
+	case api.QuorumTLSPhaseSSLQuorumPortUnification:
+		cfg = []string{"sslQuorum=true", "portUnification=true"}
+	case api.QuorumTLSPhaseSSLQuorum:
+		cfg = []string{"sslQuorum=true", "portUnification=false"}
+	}
+	if tp.Static.ReloadPeerCerts {
+		cfg = append(cfg, "sslQuorumReloadCertFiles=true")
+	}
+	return cfg
+}
+
+// quorumTLSProps returns the system properties of the quorum and election ports.
+func quorumTLSProps() []string {
+	return []string{
+		fmt.Sprintf("-Dzookeeper.ssl.quorum.keyStore.location=%s/%s", peerTLSDir, keystoreFile),
+		fmt.Sprintf("-Dzookeeper.ssl.quorum.keyStore.password=$(%s)", quorumKeystorePasswordEnv),
+		fmt.Sprintf("-Dzookeeper.ssl.quorum.trustStore.location=%s/%s", peerTLSDir, truststoreFile),
+		fmt.Sprintf("-Dzookeeper.ssl.quorum.trustStore.password=$(%s)", quorumTruststorePasswordEnv),
+	}
+}
+
+// addSecureClientToPod mounts the server secret into the zookeeper container and
+// exposes the secure client port.
+func addSecureClientToPod(pod *v1.Pod, tp *api.TLSPolicy) {
+	pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+		Name: serverTLSVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: tp.Static.Member.ServerSecret},
+		},
+	})
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != "zookeeper" {
+			continue
+		}
+		c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{
+			Name:      serverTLSVolumeName,
+			MountPath: serverTLSDir,
+			ReadOnly:  true,
+		})
+		c.Ports = append(c.Ports, v1.ContainerPort{
+			Name:          "secure-client",
+			ContainerPort: ZookeeperSecureClientPort,
+			Protocol:      v1.ProtocolTCP,
+		})
+	}
+}
+
+// HasSecureClientPort tells whether the zookeeper container of the pod serves the secure
+// client port.
+func HasSecureClientPort(pod *v1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != "zookeeper" {
+			continue
+		}
+		for _, p := range c.Ports {
+			if p.Name == "secure-client" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addQuorumTLSToPod mounts the peer secret into the zookeeper container.
+func addQuorumTLSToPod(pod *v1.Pod, tp *api.TLSPolicy) {
+	pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+		Name: peerTLSVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: tp.Static.Member.PeerSecret},
+		},
+	})
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != "zookeeper" {
+			continue
+		}
+		c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{
+			Name:      peerTLSVolumeName,
+			MountPath: peerTLSDir,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// GetOperatorTLSConfig builds the client TLS config of the operator from the operator secret.
+func GetOperatorTLSConfig(kubecli kubernetes.Interface, ns string, tp *api.TLSPolicy) (*tls.Config, error) {
+	secret, err := kubecli.CoreV1().Secrets(ns).Get(tp.Static.OperatorSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operator secret (%s): %v", tp.Static.OperatorSecret, err)
+	}
+	return zookeeperutil.NewTLSConfig(secret.Data[tlsCertFile], secret.Data[tlsKeyFile], secret.Data[tlsCAFile])
+}
+
+// SecretsHash returns a hash of the content of the given secrets.
+func SecretsHash(secrets ...*v1.Secret) string {
+	h := fnv.New32a()
+	for _, secret := range secrets {
+		var keys []string
+		for k := range secret.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		h.Write([]byte(secret.Name))
+		for _, k := range keys {
+			h.Write([]byte(k))
+			h.Write(secret.Data[k])
+		}
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// CertificateNotAfter returns when the first of the certificates of the secret expires,
+// read from the PEM certificate under tls.crt and from the JKS keystore under
+// keystore.jks. It returns false if the secret has neither.
+func CertificateNotAfter(secret *v1.Secret) (time.Time, bool, error) {
+	var certs []*x509.Certificate
+	if data, ok := secret.Data[tlsCertFile]; ok {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return time.Time{}, false, fmt.Errorf("no PEM certificate found in secret (%s)", secret.Name)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("failed to parse certificate of secret (%s): %v", secret.Name, err)
+		}
+		certs = append(certs, cert)
+	}
+	if data, ok := secret.Data[keystoreFile]; ok {
+		ksCerts, err := keystoreCertificates(data)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("failed to read keystore of secret (%s): %v", secret.Name, err)
+		}
+		certs = append(certs, ksCerts...)
+	}
+	if len(certs) == 0 {
+		return time.Time{}, false, nil
+	}
+	notAfter := certs[0].NotAfter
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+	return notAfter, true, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecretsHash(t *testing.T) {
+	newSecret := func(name, keystore string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data: map[string][]byte{
+				keystoreFile:     []byte(keystore),
+				keystorePassword: []byte("changeit"),
+			},
+		}
+	}
+	hash := SecretsHash(newSecret("server-tls", "a"), newSecret("peer-tls", "b"))
+	if h := SecretsHash(newSecret("server-tls", "a"), newSecret("peer-tls", "b")); h != hash {
+		t.Errorf("hash of the same secrets get=%s, want=%s", h, hash)
+	}
+	if h := SecretsHash(newSecret("server-tls", "a"), newSecret("peer-tls", "c")); h == hash {
+		t.Errorf("hash did not change with the keystore")
+	}
+}
+
+func TestCertificateNotAfter(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notAfter := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "zookeeper"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &v1.Secret{Data: map[string][]byte{
+		tlsCertFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}}
+	got, ok, err := CertificateNotAfter(secret)
+	if err != nil || !ok {
+		t.Fatalf("ok=%v, err=%v", ok, err)
+	}
+	if !got.Equal(notAfter) {
+		t.Errorf("notAfter get=%v, want=%v", got, notAfter)
+	}
+
+	if _, ok, err := CertificateNotAfter(&v1.Secret{}); ok || err != nil {
+		t.Errorf("secret without certificate: ok=%v, err=%v", ok, err)
+	}
+
+	// The member secrets only hold a keystore, with the member certificate and its CA.
+	ca := *template
+	ca.SerialNumber, ca.NotAfter = big.NewInt(2), notAfter.Add(-time.Hour)
+	caDER, err := x509.CreateCertificate(rand.Reader, &ca, &ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret = &v1.Secret{Data: map[string][]byte{
+		keystoreFile: newTestKeystore([]byte("encrypted key"), der, caDER),
+	}}
+	got, ok, err = CertificateNotAfter(secret)
+	if err != nil || !ok {
+		t.Fatalf("keystore: ok=%v, err=%v", ok, err)
+	}
+	if !got.Equal(ca.NotAfter) {
+		t.Errorf("keystore notAfter get=%v, want=%v", got, ca.NotAfter)
+	}
+
+	secret.Data[keystoreFile] = []byte("not a keystore")
+	if _, _, err := CertificateNotAfter(secret); err == nil {
+		t.Errorf("expected an invalid keystore to fail")
+	}
+}
+
+// newTestKeystore returns a JKS keystore with one private key entry with the given
+// certificate chain.
+func newTestKeystore(key []byte, chain ...[]byte) []byte {
+	var b bytes.Buffer
+	write := func(v interface{}) { binary.Write(&b, binary.BigEndian, v) }
+	writeUTF := func(s string) {
+		write(uint16(len(s)))
+		b.WriteString(s)
+	}
+	write([]uint32{jksMagic, 2, 1, jksPrivateKeyEntry})
+	writeUTF("zookeeper")
+	write(time.Now().UnixNano() / int64(time.Millisecond))
+	write(uint32(len(key)))
+	b.Write(key)
+	write(uint32(len(chain)))
+	for _, der := range chain {
+		writeUTF("X.509")
+		write(uint32(len(der)))
+		b.Write(der)
+	}
+	// The integrity digest.
+	b.Write(make([]byte, 20))
+	return b.Bytes()
+}
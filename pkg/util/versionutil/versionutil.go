@@ -0,0 +1,79 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versionutil
+
+import (
+	"fmt"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// Parse parses a zookeeper version, e.g. "3.5.3-beta".
+func Parse(v string) (*semver.Version, error) {
+	sv, err := semver.NewVersion(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: %v", v, err)
+	}
+	return sv, nil
+}
+
+// IsDowngrade tells whether moving from version from to version to is a downgrade.
+func IsDowngrade(from, to string) (bool, error) {
+	fv, err := Parse(from)
+	if err != nil {
+		return false, err
+	}
+	tv, err := Parse(to)
+	if err != nil {
+		return false, err
+	}
+	return tv.LessThan(*fv), nil
+}
+
+// UpgradePath tells which version changes are allowed.
+type UpgradePath struct {
+	// MaxMinorSkew is how many minor versions a single version change may move by.
+	MaxMinorSkew int
+	// AllowMinorDowngrade allows lowering the minor version.
+	AllowMinorDowngrade bool
+	// AllowMajorChange allows changing the major version.
+	AllowMajorChange bool
+}
+
+// CheckUpgradePath returns an error unless members may be moved from version from to
+// version to along the given path. Changes of the patch version are always allowed.
+func CheckUpgradePath(from, to string, p UpgradePath) error {
+	fv, err := Parse(from)
+	if err != nil {
+		return err
+	}
+	tv, err := Parse(to)
+	if err != nil {
+		return err
+	}
+	if fv.Major != tv.Major {
+		if !p.AllowMajorChange {
+			return fmt.Errorf("changing the major version from %s to %s is not allowed", from, to)
+		}
+		return nil
+	}
+	if skew := tv.Minor - fv.Minor; skew > int64(p.MaxMinorSkew) || skew < -int64(p.MaxMinorSkew) {
+		return fmt.Errorf("changing the version from %s to %s moves the minor version by more than %d", from, to, p.MaxMinorSkew)
+	}
+	if tv.Minor < fv.Minor && !p.AllowMinorDowngrade {
+		return fmt.Errorf("downgrading the minor version from %s to %s is not allowed", from, to)
+	}
+	return nil
+}
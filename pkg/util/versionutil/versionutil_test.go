@@ -0,0 +1,67 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versionutil
+
+import "testing"
+
+func TestCheckUpgradePath(t *testing.T) {
+	def := UpgradePath{MaxMinorSkew: 1}
+	tests := []struct {
+		from, to string
+		path     UpgradePath
+		wErr     bool
+	}{
+		{from: "3.5.3-beta", to: "3.5.4-beta", path: def, wErr: false},
+		{from: "3.5.4-beta", to: "3.5.3-beta", path: def, wErr: false},
+		{from: "3.4.10", to: "3.5.3-beta", path: def, wErr: false},
+		{from: "3.5.3-beta", to: "3.4.10", path: def, wErr: true},
+		{from: "3.5.3-beta", to: "3.4.10", path: UpgradePath{MaxMinorSkew: 1, AllowMinorDowngrade: true}, wErr: false},
+		{from: "3.4.10", to: "3.6.0", path: def, wErr: true},
+		{from: "3.4.10", to: "3.6.0", path: UpgradePath{MaxMinorSkew: 2}, wErr: false},
+		{from: "3.4.10", to: "3.7.0", path: UpgradePath{MaxMinorSkew: 2}, wErr: true},
+		{from: "3.6.0", to: "3.4.10", path: UpgradePath{MaxMinorSkew: 1, AllowMinorDowngrade: true}, wErr: true},
+		{from: "3.6.0", to: "3.4.10", path: UpgradePath{MaxMinorSkew: 2, AllowMinorDowngrade: true}, wErr: false},
+		{from: "3.5.3-beta", to: "4.0.0", path: def, wErr: true},
+		{from: "3.5.3-beta", to: "4.0.0", path: UpgradePath{MaxMinorSkew: 1, AllowMajorChange: true}, wErr: false},
+		{from: "3.5", to: "3.5.3-beta", path: def, wErr: true},
+	}
+	for i, tt := range tests {
+		err := CheckUpgradePath(tt.from, tt.to, tt.path)
+		if (err != nil) != tt.wErr {
+			t.Errorf("#%d: %s to %s: err get=%v, want error=%v", i, tt.from, tt.to, err, tt.wErr)
+		}
+	}
+}
+
+func TestIsDowngrade(t *testing.T) {
+	tests := []struct {
+		from, to   string
+		wDowngrade bool
+	}{
+		{from: "3.5.3-beta", to: "3.5.4-beta", wDowngrade: false},
+		{from: "3.5.4-beta", to: "3.5.3-beta", wDowngrade: true},
+		{from: "3.5.3", to: "3.5.3-beta", wDowngrade: true},
+		{from: "3.5.3-beta", to: "3.5.3-beta", wDowngrade: false},
+	}
+	for i, tt := range tests {
+		downgrade, err := IsDowngrade(tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if downgrade != tt.wDowngrade {
+			t.Errorf("#%d: %s to %s: downgrade get=%v, want=%v", i, tt.from, tt.to, downgrade, tt.wDowngrade)
+		}
+	}
+}
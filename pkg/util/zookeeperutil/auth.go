@@ -0,0 +1,30 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeperutil
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+)
+
+// SuperUser is the user the operator authenticates as when ACLs are enforced.
+const SuperUser = "super"
+
+// SuperDigest returns the digest of the super user with the given password, as expected
+// by zookeeper.DigestAuthenticationProvider.superDigest.
+func SuperDigest(password string) string {
+	h := sha1.Sum([]byte(SuperUser + ":" + password))
+	return SuperUser + ":" + base64.StdEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,24 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeperutil
+
+import "testing"
+
+func TestSuperDigest(t *testing.T) {
+	want := "super:lK75jTNcA+U9vtVEw5vB51mj/w4="
+	if d := SuperDigest("secret"); d != want {
+		t.Errorf("digest get=%q, want=%q", d, want)
+	}
+}
@@ -21,15 +21,61 @@ import (
 	"strings"
 )
 
+// AddressType is how a member is addressed. The values match the member address types
+// of the cluster spec.
+type AddressType string
+
+const (
+	AddressFQDN      AddressType = "FQDN"
+	AddressShortName AddressType = "ShortName"
+	AddressPodIP     AddressType = "PodIP"
+)
+
+// The roles of the members in the dynamic configuration.
+const (
+	RoleParticipant = "participant"
+	RoleObserver    = "observer"
+)
+
 type Member struct {
 	// Name is of the format "clusterName-ID"
 	Name string
 	// Kubernetes namespace this member runs in.
 	Namespace string
+	// AddressType is how the member is addressed, by FQDN if it is empty.
+	AddressType AddressType
+	// ClusterDomain is appended to the FQDN of the member, e.g. "cluster.local".
+	ClusterDomain string
+	// PodIP is the address of the member with the PodIP address type.
+	PodIP string
+	// Observer tells whether the member is a non-voting observer.
+	Observer bool
+	// PeerPort and LeaderPort are the quorum and leader election ports of the member,
+	// 2888 and 3888 if they are not set.
+	PeerPort   int
+	LeaderPort int
+}
+
+// Role returns the role of the member in the dynamic configuration.
+func (m *Member) Role() string {
+	if m.Observer {
+		return RoleObserver
+	}
+	return RoleParticipant
 }
 
 func (m *Member) Addr() string {
-	return fmt.Sprintf("%s.%s.%s.svc", m.Name, clusterNameFromMemberName(m.Name), m.Namespace)
+	switch m.AddressType {
+	case AddressShortName:
+		return fmt.Sprintf("%s.%s", m.Name, clusterNameFromMemberName(m.Name))
+	case AddressPodIP:
+		return m.PodIP
+	}
+	addr := fmt.Sprintf("%s.%s.%s.svc", m.Name, clusterNameFromMemberName(m.Name), m.Namespace)
+	if len(m.ClusterDomain) != 0 {
+		addr += "." + m.ClusterDomain
+	}
+	return addr
 }
 
 func (m *Member) ID() int {
@@ -86,6 +132,28 @@ func (ms MemberSet) String() string {
 	return strings.Join(mstring, ",")
 }
 
+// Participants returns the voting members of the set.
+func (ms MemberSet) Participants() MemberSet {
+	res := MemberSet{}
+	for n, m := range ms {
+		if !m.Observer {
+			res[n] = m
+		}
+	}
+	return res
+}
+
+// Observers returns the non-voting members of the set.
+func (ms MemberSet) Observers() MemberSet {
+	res := MemberSet{}
+	for n, m := range ms {
+		if m.Observer {
+			res[n] = m
+		}
+	}
+	return res
+}
+
 func (ms MemberSet) PickOne() *Member {
 	for _, m := range ms {
 		return m
@@ -112,23 +180,67 @@ func (ms MemberSet) MaxMemberID() int {
 	return maxID
 }
 
-func (ms MemberSet) ClientHostList() []string {
+// ServerConfig returns the dynamic configuration line of the member with the given role.
+// The plain client port only listens on localhost if localClientPort is set.
+func (m *Member) ServerConfig(role string, localClientPort bool) string {
+	clientAddr := m.Addr()
+	if localClientPort {
+		clientAddr = "127.0.0.1"
+	}
+	peerPort, leaderPort := m.PeerPort, m.LeaderPort
+	if peerPort == 0 {
+		peerPort = 2888
+	}
+	if leaderPort == 0 {
+		leaderPort = 3888
+	}
+	return fmt.Sprintf("server.%d=%s:%d:%d:%s;%s:2181", m.ID(), m.Addr(), peerPort, leaderPort, role, clientAddr)
+}
+
+// ClientHostList returns the addresses of the members on the given client port. Members
+// addressed by a pod IP that is not known yet are left out.
+func (ms MemberSet) ClientHostList(port int) []string {
 	hosts := make([]string, 0)
 	for _, m := range ms {
-		hosts = append(hosts, fmt.Sprintf("%s:2181", m.Addr()))
+		if len(m.Addr()) == 0 {
+			continue
+		}
+		hosts = append(hosts, fmt.Sprintf("%s:%d", m.Addr(), port))
 	}
 	return hosts
 }
 
-func (ms MemberSet) ClusterConfig() []string {
+func (ms MemberSet) ClusterConfig(localClientPort bool) []string {
 	clusterConfig := make([]string, 0)
 	for _, m := range ms {
-		clusterConfig = append(clusterConfig, fmt.Sprintf("server.%d=%s:2888:3888:participant;%s:2181", m.ID(), m.Addr(), m.Addr()))
+		clusterConfig = append(clusterConfig, m.ServerConfig(m.Role(), localClientPort))
 	}
 	sort.Strings(clusterConfig)
 	return clusterConfig
 }
 
+// MemberNameFromServerConfig returns the name of the member of a dynamic configuration line.
+// The name is built from the server ID since the address may be a pod IP.
+func MemberNameFromServerConfig(clusterName, serverConfig string) (string, error) {
+	i := strings.Index(serverConfig, "=")
+	if i == -1 || !strings.HasPrefix(serverConfig, "server.") {
+		return "", fmt.Errorf("unexpected server config: %s", serverConfig)
+	}
+	id, err := strconv.Atoi(serverConfig[len("server."):i])
+	if err != nil {
+		return "", fmt.Errorf("unexpected server config: %s", serverConfig)
+	}
+	return fmt.Sprintf("%s-%d", clusterName, id), nil
+}
+
+// IsObserverServerConfig tells whether the dynamic configuration line is the one of an observer.
+func IsObserverServerConfig(serverConfig string) bool {
+	if i := strings.Index(serverConfig, ";"); i != -1 {
+		serverConfig = serverConfig[:i]
+	}
+	return strings.HasSuffix(serverConfig, ":"+RoleObserver)
+}
+
 func clusterNameFromMemberName(mn string) string {
 	i := strings.LastIndex(mn, "-")
 	if i == -1 {
@@ -14,7 +14,10 @@
 
 package zookeeperutil
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestMemberSetIsEqual(t *testing.T) {
 	ma := &Member{Name: "a"}
@@ -54,3 +57,105 @@ func TestMemberSetIsEqual(t *testing.T) {
 		}
 	}
 }
+
+func TestMemberNameFromServerConfig(t *testing.T) {
+	m := &Member{Name: "example-3", Namespace: "default"}
+	tests := []struct {
+		serverConfig string
+		wName        string
+		wErr         bool
+	}{{
+		serverConfig: m.ServerConfig("participant", false),
+		wName:        "example-3",
+	}, {
+		serverConfig: m.ServerConfig("participant", true),
+		wName:        "example-3",
+	}, {
+		serverConfig: "server.3=10.0.0.3:2888:3888:participant;10.0.0.3:2181",
+		wName:        "example-3",
+	}, {
+		serverConfig: "example-3",
+		wErr:         true,
+	}}
+	for i, tt := range tests {
+		name, err := MemberNameFromServerConfig("example", tt.serverConfig)
+		if (err != nil) != tt.wErr {
+			t.Errorf("#%d: err get=%v, want error=%v", i, err, tt.wErr)
+			continue
+		}
+		if name != tt.wName {
+			t.Errorf("#%d: name get=%q, want=%q", i, name, tt.wName)
+		}
+	}
+}
+
+func TestMemberAddr(t *testing.T) {
+	tests := []struct {
+		m     Member
+		wAddr string
+	}{{
+		m:     Member{Name: "example-1", Namespace: "default"},
+		wAddr: "example-1.example.default.svc",
+	}, {
+		m:     Member{Name: "example-1", Namespace: "default", ClusterDomain: "cluster.local"},
+		wAddr: "example-1.example.default.svc.cluster.local",
+	}, {
+		m:     Member{Name: "example-1", Namespace: "default", AddressType: AddressShortName},
+		wAddr: "example-1.example",
+	}, {
+		m:     Member{Name: "example-1", Namespace: "default", AddressType: AddressPodIP, PodIP: "10.0.0.1"},
+		wAddr: "10.0.0.1",
+	}}
+	for i, tt := range tests {
+		if addr := tt.m.Addr(); addr != tt.wAddr {
+			t.Errorf("#%d: addr get=%q, want=%q", i, addr, tt.wAddr)
+		}
+	}
+}
+
+func TestClusterConfigRoles(t *testing.T) {
+	ms := NewMemberSet(
+		&Member{Name: "example-1", Namespace: "default"},
+		&Member{Name: "example-2", Namespace: "default", Observer: true},
+	)
+	w := []string{
+		"server.1=example-1.example.default.svc:2888:3888:participant;example-1.example.default.svc:2181",
+		"server.2=example-2.example.default.svc:2888:3888:observer;example-2.example.default.svc:2181",
+	}
+	cc := ms.ClusterConfig(false)
+	if len(cc) != len(w) {
+		t.Fatalf("config get=%v, want=%v", cc, w)
+	}
+	for i := range w {
+		if cc[i] != w[i] {
+			t.Errorf("#%d: line get=%q, want=%q", i, cc[i], w[i])
+		}
+		if observer := IsObserverServerConfig(cc[i]); observer != ms[fmt.Sprintf("example-%d", i+1)].Observer {
+			t.Errorf("#%d: observer get=%v, want=%v", i, observer, !observer)
+		}
+	}
+	if n := ms.Participants().Size(); n != 1 {
+		t.Errorf("participants get=%d, want=1", n)
+	}
+	if n := ms.Observers().Size(); n != 1 {
+		t.Errorf("observers get=%d, want=1", n)
+	}
+}
+
+func TestServerConfigPorts(t *testing.T) {
+	tests := []struct {
+		m       Member
+		wConfig string
+	}{{
+		m:       Member{Name: "example-1", Namespace: "default"},
+		wConfig: "server.1=example-1.example.default.svc:2888:3888:participant;example-1.example.default.svc:2181",
+	}, {
+		m:       Member{Name: "example-1", Namespace: "default", PeerPort: 12888, LeaderPort: 13888},
+		wConfig: "server.1=example-1.example.default.svc:12888:13888:participant;example-1.example.default.svc:2181",
+	}}
+	for i, tt := range tests {
+		if config := tt.m.ServerConfig(RoleParticipant, false); config != tt.wConfig {
+			t.Errorf("#%d: config get=%q, want=%q", i, config, tt.wConfig)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeperutil
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ModeLeader     = "leader"
+	ModeFollower   = "follower"
+	ModeObserver   = "observer"
+	ModeStandalone = "standalone"
+
+	fourLetterWordTimeout = 2 * time.Second
+)
+
+var (
+	ErrNotWhitelisted = errors.New("four letter word is not whitelisted")
+	ErrNotServing     = errors.New("server is not currently serving requests")
+)
+
+// ServerStats is the subset of the srvr output the operator relies on.
+type ServerStats struct {
+	Mode string
+	Zxid int64
+}
+
+// FourLetterWord sends the four letter word cmd to the server at host and returns its answer.
+// The connection uses TLS if tlsConfig is set.
+func FourLetterWord(host, cmd string, tlsConfig *tls.Config) (string, error) {
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: fourLetterWordTimeout}, "tcp", host, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", host, fourLetterWordTimeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(fourLetterWordTimeout))
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	return checkFourLetterWordResponse(string(data))
+}
+
+func checkFourLetterWordResponse(resp string) (string, error) {
+	switch {
+	case strings.Contains(resp, "is not in the whitelist"):
+		return "", ErrNotWhitelisted
+	case strings.Contains(resp, "not currently serving requests"):
+		return "", ErrNotServing
+	}
+	return resp, nil
+}
+
+// IsServerOK tells whether the server at host answers imok to ruok.
+func IsServerOK(host string, tlsConfig *tls.Config) bool {
+	resp, err := FourLetterWord(host, "ruok", tlsConfig)
+	return err == nil && resp == "imok"
+}
+
+// GetServerStats returns the srvr stats of the server at host.
+func GetServerStats(host string, tlsConfig *tls.Config) (*ServerStats, error) {
+	resp, err := FourLetterWord(host, "srvr", tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return parseSrvr(resp)
+}
+
+// GetMonitorStats returns the mntr key value pairs of the server at host.
+func GetMonitorStats(host string, tlsConfig *tls.Config) (map[string]string, error) {
+	resp, err := FourLetterWord(host, "mntr", tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return parseMntr(resp), nil
+}
+
+func parseSrvr(resp string) (*ServerStats, error) {
+	stats := &ServerStats{}
+	for _, line := range strings.Split(resp, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "Mode":
+			stats.Mode = value
+		case "Zxid":
+			zxid, err := strconv.ParseInt(value, 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid zxid %q: %v", value, err)
+			}
+			stats.Zxid = zxid
+		}
+	}
+	if len(stats.Mode) == 0 {
+		return nil, fmt.Errorf("unexpected srvr response: %q", resp)
+	}
+	return stats, nil
+}
+
+func parseMntr(resp string) map[string]string {
+	stats := make(map[string]string)
+	for _, line := range strings.Split(resp, "\n") {
+		kv := strings.SplitN(line, "\t", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		stats[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return stats
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The zookeeper-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeperutil
+
+import "testing"
+
+func TestParseSrvr(t *testing.T) {
+	tests := []struct {
+		resp  string
+		wMode string
+		wZxid int64
+		wErr  bool
+	}{{
+		resp: "Zookeeper version: 3.5.3-beta-8ce24f9e675cbefffb8f21a47e06b42864475a60, built on 04/03/2017 16:19 GMT\n" +
+			"Latency min/avg/max: 0/0/12\nReceived: 96\nSent: 95\nConnections: 1\nOutstanding: 0\n" +
+			"Zxid: 0x100000004\nMode: leader\nNode count: 5\nProposal sizes last/min/max: 32/32/36\n",
+		wMode: ModeLeader,
+		wZxid: 0x100000004,
+	}, {
+		resp:  "Zxid: 0x0\nMode: follower\n",
+		wMode: ModeFollower,
+	}, {
+		resp: "Zxid: 0xzz\nMode: follower\n",
+		wErr: true,
+	}, {
+		resp: "",
+		wErr: true,
+	}}
+	for i, tt := range tests {
+		stats, err := parseSrvr(tt.resp)
+		if (err != nil) != tt.wErr {
+			t.Errorf("#%d: err get=%v, want error=%v", i, err, tt.wErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if stats.Mode != tt.wMode || stats.Zxid != tt.wZxid {
+			t.Errorf("#%d: stats get=%+v, want mode=%s zxid=%d", i, stats, tt.wMode, tt.wZxid)
+		}
+	}
+}
+
+func TestParseMntr(t *testing.T) {
+	resp := "zk_version\t3.5.3-beta\nzk_server_state\tleader\nzk_synced_followers\t2\n"
+	stats := parseMntr(resp)
+	if stats["zk_server_state"] != ModeLeader {
+		t.Errorf("server state get=%q, want=%q", stats["zk_server_state"], ModeLeader)
+	}
+	if stats["zk_synced_followers"] != "2" {
+		t.Errorf("synced followers get=%q, want=%q", stats["zk_synced_followers"], "2")
+	}
+}
+
+func TestCheckFourLetterWordResponse(t *testing.T) {
+	tests := []struct {
+		resp string
+		wErr error
+	}{{
+		resp: "imok",
+	}, {
+		resp: "srvr is not executed because it is not in the whitelist.\n",
+		wErr: ErrNotWhitelisted,
+	}, {
+		resp: "This ZooKeeper instance is not currently serving requests\n",
+		wErr: ErrNotServing,
+	}}
+	for i, tt := range tests {
+		_, err := checkFourLetterWordResponse(tt.resp)
+		if err != tt.wErr {
+			t.Errorf("#%d: err get=%v, want=%v", i, err, tt.wErr)
+		}
+	}
+}
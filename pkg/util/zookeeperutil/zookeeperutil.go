@@ -15,6 +15,8 @@
 package zookeeperutil
 
 import (
+	"crypto/tls"
+	"net"
 	"sort"
 	"strings"
 	"time"
@@ -27,8 +29,39 @@ import (
 	"github.com/blafrisch/go-zookeeper/zk"
 )
 
-func GetClusterConfig(hosts []string) ([]string, error) {
-	conn, _, err := zk.Connect(hosts, time.Second)
+// ConnConfig configures the connections of the operator to the ensemble.
+type ConnConfig struct {
+	// TLSConfig is set if the members are reached on the secure client port.
+	TLSConfig *tls.Config
+	// SuperPassword is the password to authenticate as the super user with, if set.
+	SuperPassword string
+}
+
+// connect connects to the given hosts, over TLS if a TLS config is set, and authenticates
+// as the super user if a password is set.
+func connect(hosts []string, cc ConnConfig) (*zk.Conn, <-chan zk.Event, error) {
+	var conn *zk.Conn
+	var events <-chan zk.Event
+	var err error
+	if cc.TLSConfig != nil {
+		dialer := func(network, address string, timeout time.Duration) (net.Conn, error) {
+			return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, cc.TLSConfig)
+		}
+		conn, events, err = zk.Connect(hosts, time.Second, zk.WithDialer(dialer))
+	} else {
+		conn, events, err = zk.Connect(hosts, time.Second)
+	}
+	if err != nil || len(cc.SuperPassword) == 0 {
+		return conn, events, err
+	}
+	if err := conn.AddAuth("digest", []byte(SuperUser+":"+cc.SuperPassword)); err != nil {
+		return conn, events, err
+	}
+	return conn, events, nil
+}
+
+func GetClusterConfig(hosts []string, cc ConnConfig) ([]string, error) {
+	conn, _, err := connect(hosts, cc)
 	defer conn.Close()
 	if err != nil {
 		glog.Error("Failed to connect to ZK hosts: ", hosts)
@@ -50,8 +83,8 @@ func GetClusterConfig(hosts []string) ([]string, error) {
 	return clusterConfig, nil
 }
 
-func ReconfigureCluster(hosts []string, desiredConfig []string) ([]string, error) {
-	conn, _, err := zk.Connect(hosts, time.Second)
+func ReconfigureCluster(hosts []string, desiredConfig []string, cc ConnConfig) ([]string, error) {
+	conn, _, err := connect(hosts, cc)
 	defer conn.Close()
 	if err != nil {
 		glog.Error("Failed to connect to ZK hosts: ", hosts)